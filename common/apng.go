@@ -0,0 +1,150 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+)
+
+var png_signature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// Encode_apng writes frames as a looping animated PNG. Every frame must have
+// the same size; delay is the time between frames in 100ths of a second.
+// The first frame doubles as the default image for viewers without APNG support.
+func Encode_apng(w io.Writer, frames []*image.RGBA, delay int) error {
+
+	if len(frames) == 0 {
+		return errors.New("apng: no frames to encode")
+	}
+
+	// fcTL stores the delay numerator in 16 bits
+	if delay < 0 || delay > math.MaxUint16 {
+		return errors.New("apng: delay must be between 0 and 65535")
+	}
+
+	bounds := frames[0].Bounds()
+	width := uint32(bounds.Dx())
+	height := uint32(bounds.Dy())
+
+	for _, frame := range frames {
+		if frame.Bounds().Dx() != bounds.Dx() || frame.Bounds().Dy() != bounds.Dy() {
+			return errors.New("apng: frames must all be the same size")
+		}
+	}
+
+	if _, err := w.Write(png_signature); err != nil {
+		return err
+	}
+
+	// IHDR: 8 bit RGBA, no interlacing
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	ihdr[8] = 8
+	ihdr[9] = 6
+	if err := write_chunk(w, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	// acTL: number of frames, loop forever
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(frames)))
+	binary.BigEndian.PutUint32(actl[4:8], 0)
+	if err := write_chunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	sequence := uint32(0)
+
+	for f, frame := range frames {
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], sequence)
+		binary.BigEndian.PutUint32(fctl[4:8], width)
+		binary.BigEndian.PutUint32(fctl[8:12], height)
+		// x and y offsets stay at zero
+		binary.BigEndian.PutUint16(fctl[20:22], uint16(delay))
+		binary.BigEndian.PutUint16(fctl[22:24], 100)
+		fctl[24] = 0 // dispose: none
+		fctl[25] = 0 // blend: source
+		if err := write_chunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		sequence++
+
+		data, err := compress_frame(frame)
+		if err != nil {
+			return err
+		}
+
+		if f == 0 {
+			err = write_chunk(w, "IDAT", data)
+		} else {
+			fdat := make([]byte, 4+len(data))
+			binary.BigEndian.PutUint32(fdat[0:4], sequence)
+			copy(fdat[4:], data)
+			err = write_chunk(w, "fdAT", fdat)
+			sequence++
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return write_chunk(w, "IEND", nil)
+}
+
+// compress_frame produces the zlib stream of unfiltered RGBA scanlines
+func compress_frame(frame *image.RGBA) ([]byte, error) {
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	bounds := frame.Bounds()
+	row_bytes := bounds.Dx() * 4
+
+	for j := bounds.Min.Y; j < bounds.Max.Y; j++ {
+		start := frame.PixOffset(bounds.Min.X, j)
+
+		// Filter type 0 (none) precedes every scanline
+		if _, err := zw.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(frame.Pix[start : start+row_bytes]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func write_chunk(w io.Writer, name string, data []byte) error {
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
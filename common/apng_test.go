@@ -0,0 +1,141 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func test_frames(n int, width int, height int) []*image.RGBA {
+	frames := make([]*image.RGBA, n)
+	for f := range frames {
+		frames[f] = image.NewRGBA(image.Rect(0, 0, width, height))
+		for j := 0; j < height; j++ {
+			for i := 0; i < width; i++ {
+				frames[f].SetRGBA(i, j, color.RGBA{uint8(40 * f), uint8(20 * i), uint8(30 * j), 255})
+			}
+		}
+	}
+	return frames
+}
+
+type png_chunk struct {
+	name string
+	data []byte
+}
+
+// read_chunks splits a PNG stream into chunks, checking every CRC
+func read_chunks(t *testing.T, data []byte) []png_chunk {
+	t.Helper()
+
+	if !bytes.HasPrefix(data, png_signature) {
+		t.Fatal("missing PNG signature")
+	}
+	data = data[len(png_signature):]
+
+	var chunks []png_chunk
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatalf("truncated chunk of %d bytes", len(data))
+		}
+		length := binary.BigEndian.Uint32(data[0:4])
+		name := string(data[4:8])
+		body := data[8 : 8+length]
+		crc := binary.BigEndian.Uint32(data[8+length : 12+length])
+
+		if want := crc32.ChecksumIEEE(data[4 : 8+length]); crc != want {
+			t.Errorf("%s chunk CRC is %08x, want %08x", name, crc, want)
+		}
+
+		chunks = append(chunks, png_chunk{name: name, data: body})
+		data = data[12+length:]
+	}
+	return chunks
+}
+
+func TestEncode_apng(t *testing.T) {
+
+	frames := test_frames(3, 5, 4)
+
+	var buf bytes.Buffer
+	if err := Encode_apng(&buf, frames, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := read_chunks(t, buf.Bytes())
+
+	var names []string
+	for _, chunk := range chunks {
+		names = append(names, chunk.name)
+	}
+	want := []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if len(names) != len(want) {
+		t.Fatalf("chunks are %v, want %v", names, want)
+	}
+	for k := range want {
+		if names[k] != want[k] {
+			t.Fatalf("chunks are %v, want %v", names, want)
+		}
+	}
+
+	if n := binary.BigEndian.Uint32(chunks[1].data[0:4]); n != 3 {
+		t.Errorf("acTL has %d frames, want 3", n)
+	}
+
+	// fcTL and fdAT share one sequence that counts up from zero
+	sequence := uint32(0)
+	for _, chunk := range chunks {
+		if chunk.name != "fcTL" && chunk.name != "fdAT" {
+			continue
+		}
+		if got := binary.BigEndian.Uint32(chunk.data[0:4]); got != sequence {
+			t.Errorf("%s has sequence number %d, want %d", chunk.name, got, sequence)
+		}
+		sequence++
+	}
+
+	// Viewers without APNG support show the first frame
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 5; i++ {
+			r, g, b, a := img.At(i, j).RGBA()
+			got := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+			if want := frames[0].RGBAAt(i, j); got != want {
+				t.Errorf("pixel (%d, %d) is %v, want %v", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestEncode_apngRejectsMismatchedFrames(t *testing.T) {
+
+	frames := []*image.RGBA{image.NewRGBA(image.Rect(0, 0, 2, 2)), image.NewRGBA(image.Rect(0, 0, 3, 2))}
+
+	if err := Encode_apng(&bytes.Buffer{}, frames, 10); err == nil {
+		t.Error("expected an error for frames of different sizes")
+	}
+	if err := Encode_apng(&bytes.Buffer{}, nil, 10); err == nil {
+		t.Error("expected an error for no frames")
+	}
+}
+
+func TestEncode_apngRejectsBadDelay(t *testing.T) {
+
+	frames := test_frames(2, 2, 2)
+
+	for _, delay := range []int{-1, 65536} {
+		if err := Encode_apng(&bytes.Buffer{}, frames, delay); err == nil {
+			t.Errorf("expected an error for a delay of %d", delay)
+		}
+	}
+	if err := Encode_apng(&bytes.Buffer{}, frames, 65535); err != nil {
+		t.Errorf("delay of 65535 rejected: %v", err)
+	}
+}
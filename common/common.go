@@ -18,6 +18,17 @@ func Degrees_to_radians(degrees float64) float64 {
 	return degrees * Pi / 180.0
 }
 
+// Power_heuristic weights a sample drawn with density pdf against another
+// strategy that would draw it with other_pdf, for multiple importance sampling
+func Power_heuristic(pdf float64, other_pdf float64) float64 {
+	a := pdf * pdf
+	b := other_pdf * other_pdf
+	if a+b == 0 {
+		return 0
+	}
+	return a / (a + b)
+}
+
 func Random_float(min float64, max float64) float64 {
 	return min + (max-min)*rand.Float64()
 }
@@ -0,0 +1,161 @@
+package common
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"sort"
+)
+
+// Encode_gif writes frames as a looping animated GIF. A single palette is
+// built for the whole sequence with median cut so colors stay stable between
+// frames. delay is the time between frames in 100ths of a second.
+func Encode_gif(w io.Writer, frames []*image.RGBA, delay int, dither bool) error {
+
+	palette := Median_cut_palette(frames, 256)
+
+	var drawer draw.Drawer = draw.Src
+	if dither {
+		drawer = draw.FloydSteinberg
+	}
+
+	anim := gif.GIF{LoopCount: 0}
+
+	for _, frame := range frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(bounds, palette)
+		drawer.Draw(paletted, bounds, frame, bounds.Min)
+
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	return gif.EncodeAll(w, &anim)
+}
+
+// color_bucket is one entry of the 15-bit color histogram used by median cut
+type color_bucket struct {
+	rgb   [3]uint8
+	count int
+}
+
+// Median_cut_palette builds a palette of at most n_colors from every pixel in
+// frames by recursively splitting the box with the widest channel range at
+// its median.
+func Median_cut_palette(frames []*image.RGBA, n_colors int) color.Palette {
+
+	// Quantize to 5 bits per channel so the histogram stays small
+	histogram := make(map[uint16]int)
+
+	for _, frame := range frames {
+		bounds := frame.Bounds()
+		for j := bounds.Min.Y; j < bounds.Max.Y; j++ {
+			for i := bounds.Min.X; i < bounds.Max.X; i++ {
+				c := frame.RGBAAt(i, j)
+				key := uint16(c.R>>3)<<10 | uint16(c.G>>3)<<5 | uint16(c.B>>3)
+				histogram[key]++
+			}
+		}
+	}
+
+	buckets := make([]color_bucket, 0, len(histogram))
+	for key, count := range histogram {
+		buckets = append(buckets, color_bucket{
+			rgb: [3]uint8{
+				uint8(key>>10&0x1f)<<3 | 0x4,
+				uint8(key>>5&0x1f)<<3 | 0x4,
+				uint8(key&0x1f)<<3 | 0x4,
+			},
+			count: count,
+		})
+	}
+
+	if len(buckets) == 0 {
+		return color.Palette{color.RGBA{0, 0, 0, 255}}
+	}
+
+	boxes := [][]color_bucket{buckets}
+
+	for len(boxes) < n_colors {
+
+		// Pick the splittable box with the widest channel range
+		best, best_axis, best_range := -1, 0, 0
+		for b, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			axis, rng := widest_channel(box)
+			if rng > best_range {
+				best, best_axis, best_range = b, axis, rng
+			}
+		}
+
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool {
+			return box[i].rgb[best_axis] < box[j].rgb[best_axis]
+		})
+
+		// Split at the pixel-weighted median
+		total := 0
+		for _, bucket := range box {
+			total += bucket.count
+		}
+
+		split, seen := 1, 0
+		for k, bucket := range box {
+			seen += bucket.count
+			if seen*2 >= total {
+				split = k + 1
+				break
+			}
+		}
+		if split >= len(box) {
+			split = len(box) - 1
+		}
+
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b, total int
+		for _, bucket := range box {
+			r += int(bucket.rgb[0]) * bucket.count
+			g += int(bucket.rgb[1]) * bucket.count
+			b += int(bucket.rgb[2]) * bucket.count
+			total += bucket.count
+		}
+		palette = append(palette, color.RGBA{uint8(r / total), uint8(g / total), uint8(b / total), 255})
+	}
+
+	return palette
+}
+
+func widest_channel(box []color_bucket) (int, int) {
+
+	axis, widest := 0, -1
+
+	for c := 0; c < 3; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, bucket := range box {
+			if bucket.rgb[c] < lo {
+				lo = bucket.rgb[c]
+			}
+			if bucket.rgb[c] > hi {
+				hi = bucket.rgb[c]
+			}
+		}
+		if int(hi)-int(lo) > widest {
+			axis, widest = c, int(hi)-int(lo)
+		}
+	}
+
+	return axis, widest
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestEncode_gif(t *testing.T) {
+
+	// Channel values at the centre of the 5 bit histogram bins survive
+	// median cut exactly
+	colors := []color.RGBA{
+		{4, 4, 4, 255},
+		{252, 12, 4, 255},
+		{4, 132, 252, 255},
+		{100, 204, 60, 255},
+	}
+
+	frames := make([]*image.RGBA, 2)
+	for f := range frames {
+		frames[f] = image.NewRGBA(image.Rect(0, 0, 4, 3))
+		for j := 0; j < 3; j++ {
+			for i := 0; i < 4; i++ {
+				frames[f].SetRGBA(i, j, colors[(i+j+f)%len(colors)])
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encode_gif(&buf, frames, 7, false); err != nil {
+		t.Fatal(err)
+	}
+
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(anim.Image) != len(frames) {
+		t.Fatalf("decoded %d frames, want %d", len(anim.Image), len(frames))
+	}
+	if anim.LoopCount != 0 {
+		t.Errorf("loop count is %d, want 0 (forever)", anim.LoopCount)
+	}
+
+	for f, img := range anim.Image {
+		if anim.Delay[f] != 7 {
+			t.Errorf("frame %d delay is %d, want 7", f, anim.Delay[f])
+		}
+		for j := 0; j < 3; j++ {
+			for i := 0; i < 4; i++ {
+				r, g, b, a := img.At(i, j).RGBA()
+				got := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+				if want := frames[f].RGBAAt(i, j); got != want {
+					t.Errorf("frame %d pixel (%d, %d) is %v, want %v", f, i, j, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestMedian_cut_palette(t *testing.T) {
+
+	frames := test_frames(4, 16, 16)
+
+	palette := Median_cut_palette(frames, 8)
+	if len(palette) == 0 || len(palette) > 8 {
+		t.Errorf("palette has %d colors, want 1 to 8", len(palette))
+	}
+}
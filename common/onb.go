@@ -0,0 +1,54 @@
+package common
+
+import "math"
+
+// Onb is an orthonormal basis built around a single direction (w)
+type Onb struct {
+	u, v, w Vec3
+}
+
+func NewOnb(n Vec3) Onb {
+	w := Unit_vector(n)
+
+	a := NewVec3(1, 0, 0)
+	if math.Abs(w.X()) > 0.9 {
+		a = NewVec3(0, 1, 0)
+	}
+
+	v := Unit_vector(Cross(w, a))
+	u := Cross(w, v)
+
+	return Onb{u: u, v: v, w: w}
+}
+
+func (o *Onb) U() Vec3 {
+	return o.u
+}
+
+func (o *Onb) V() Vec3 {
+	return o.v
+}
+
+func (o *Onb) W() Vec3 {
+	return o.w
+}
+
+// Local transforms a vector expressed in basis coordinates to world space
+func (o *Onb) Local(a Vec3) Vec3 {
+	return o.u.Mult(a.X()).Add(o.v.Mult(a.Y())).Add(o.w.Mult(a.Z()))
+}
+
+// To_local expresses a world space vector in basis coordinates
+func (o *Onb) To_local(a Vec3) Vec3 {
+	return NewVec3(Dot(a, o.u), Dot(a, o.v), Dot(a, o.w))
+}
+
+// NewOnbTangent builds a basis around n whose u axis follows the tangent t as
+// closely as possible, for surfaces whose look depends on a direction
+func NewOnbTangent(n Vec3, t Vec3) Onb {
+	w := Unit_vector(n)
+	u := Unit_vector(t.Sub(w.Mult(Dot(w, t))))
+	v := Cross(w, u)
+
+	return Onb{u: u, v: v, w: w}
+}
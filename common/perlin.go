@@ -0,0 +1,100 @@
+package common
+
+import (
+	"math"
+	"math/rand"
+)
+
+const perlin_point_count = 256
+
+// Perlin is gradient noise: smooth pseudo random values that vary on the
+// scale of one unit, built from random gradients at the lattice points
+type Perlin struct {
+	gradients [perlin_point_count]Vec3
+	perm_x    [perlin_point_count]int
+	perm_y    [perlin_point_count]int
+	perm_z    [perlin_point_count]int
+}
+
+func NewPerlin() Perlin {
+
+	var p Perlin
+
+	for i := range p.gradients {
+		p.gradients[i] = Unit_vector(RandomVector(-1, 1))
+	}
+
+	perlin_generate_perm(&p.perm_x)
+	perlin_generate_perm(&p.perm_y)
+	perlin_generate_perm(&p.perm_z)
+
+	return p
+}
+
+// Noise returns a value in roughly [-1, 1]
+func (p *Perlin) Noise(point Point3) float64 {
+
+	u := point.X() - math.Floor(point.X())
+	v := point.Y() - math.Floor(point.Y())
+	w := point.Z() - math.Floor(point.Z())
+
+	i := int(math.Floor(point.X()))
+	j := int(math.Floor(point.Y()))
+	k := int(math.Floor(point.Z()))
+
+	var c [2][2][2]Vec3
+
+	for di := 0; di < 2; di++ {
+		for dj := 0; dj < 2; dj++ {
+			for dk := 0; dk < 2; dk++ {
+				c[di][dj][dk] = p.gradients[p.perm_x[(i+di)&255]^p.perm_y[(j+dj)&255]^p.perm_z[(k+dk)&255]]
+			}
+		}
+	}
+
+	// Hermite smoothing hides the lattice
+	uu := u * u * (3 - 2*u)
+	vv := v * v * (3 - 2*v)
+	ww := w * w * (3 - 2*w)
+
+	accum := 0.0
+	for di := 0; di < 2; di++ {
+		for dj := 0; dj < 2; dj++ {
+			for dk := 0; dk < 2; dk++ {
+				fi, fj, fk := float64(di), float64(dj), float64(dk)
+				weight := NewVec3(u-fi, v-fj, w-fk)
+				accum += (fi*uu + (1-fi)*(1-uu)) *
+					(fj*vv + (1-fj)*(1-vv)) *
+					(fk*ww + (1-fk)*(1-ww)) *
+					Dot(c[di][dj][dk], weight)
+			}
+		}
+	}
+
+	return accum
+}
+
+// Turb sums depth octaves of noise, each at twice the frequency and half the
+// weight of the last, giving a turbulent look
+func (p *Perlin) Turb(point Point3, depth int) float64 {
+
+	accum := 0.0
+	weight := 1.0
+
+	for i := 0; i < depth; i++ {
+		accum += weight * p.Noise(point)
+		weight *= 0.5
+		point = point.Mult(2)
+	}
+
+	return math.Abs(accum)
+}
+
+func perlin_generate_perm(perm *[perlin_point_count]int) {
+	for i := range perm {
+		perm[i] = i
+	}
+	rand.Shuffle(len(perm), func(i, j int) {
+		perm[i], perm[j] = perm[j], perm[i]
+	})
+}
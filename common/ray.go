@@ -1,10 +1,27 @@
 package common
 
 type Ray struct {
-	Origin    Point3
-	Direction Vec3
+	Origin     Point3
+	Direction  Vec3
+	Stats      *Traversal_stats // counts acceleration structure work when set
+	Kind       Ray_kind         // what the ray is for, checked against visibility flags
+	Wavelength float64          // nm of the hero wavelength when rendering spectrally, otherwise zero
 }
 
+// Ray_kind tells objects what a ray is for, so they can hide from some kinds.
+// Rays of no particular kind see everything.
+type Ray_kind uint8
+
+const (
+	Ray_camera       Ray_kind = 1 << iota // leaving the camera
+	Ray_diffuse                           // bounced off a diffuse surface or out of a volume
+	Ray_reflection                        // mirror or glossy reflection
+	Ray_transmission                      // refracted through a surface
+	Ray_shadow                            // testing whether a light is blocked
+
+	Ray_all = Ray_camera | Ray_diffuse | Ray_reflection | Ray_transmission | Ray_shadow
+)
+
 func NewRay(origin Point3, direction Vec3) Ray {
 	return Ray{Origin: origin, Direction: direction}
 }
@@ -12,3 +29,28 @@ func NewRay(origin Point3, direction Vec3) Ray {
 func (r *Ray) At(t float64) Point3 {
 	return r.Origin.Add(r.Direction.Mult(t))
 }
+
+// With returns a copy of the ray moved to a new origin and direction, as
+// when transforming it into an object's space, keeping everything else
+func (r *Ray) With(origin Point3, direction Vec3) Ray {
+	moved := *r
+	moved.Origin = origin
+	moved.Direction = direction
+	return moved
+}
+
+// Traversal_stats counts the bounding volume nodes and primitives a ray was
+// tested against. Nodes are counted by the BVH, primitives by the spheres,
+// quads and triangles themselves, so a list or transform under a leaf adds
+// every primitive it holds.
+type Traversal_stats struct {
+	Nodes      int
+	Primitives int
+}
+
+// Count_primitive records one primitive intersection test for r
+func (r *Ray) Count_primitive() {
+	if r.Stats != nil {
+		r.Stats.Primitives++
+	}
+}
@@ -0,0 +1,250 @@
+package common
+
+import (
+	"math"
+)
+
+// Spectral rendering. Each path carries a few wavelengths instead of RGB,
+// so effects that depend on the wavelength, like the dispersion of glass,
+// can be followed exactly. RGB colors from textures and lights are turned
+// into spectra on the fly, and the light found is turned back into RGB
+// through the CIE XYZ color matching functions.
+
+// N_spectrum_samples is the number of wavelengths a path carries
+const N_spectrum_samples = 4
+
+const (
+	lambda_min = 360.0
+	lambda_max = 830.0
+)
+
+// Sampled_spectrum holds a spectral quantity at a path's wavelengths
+type Sampled_spectrum [N_spectrum_samples]float64
+
+func NewSampled_spectrum(value float64) Sampled_spectrum {
+	var s Sampled_spectrum
+	for i := range s {
+		s[i] = value
+	}
+	return s
+}
+
+func (s Sampled_spectrum) Add(other Sampled_spectrum) Sampled_spectrum {
+	for i := range s {
+		s[i] += other[i]
+	}
+	return s
+}
+
+func (s Sampled_spectrum) Mult(t float64) Sampled_spectrum {
+	for i := range s {
+		s[i] *= t
+	}
+	return s
+}
+
+func (s Sampled_spectrum) Max() float64 {
+	max := s[0]
+	for _, value := range s[1:] {
+		max = math.Max(max, value)
+	}
+	return max
+}
+
+func Spectrum_multiply(a Sampled_spectrum, b Sampled_spectrum) Sampled_spectrum {
+	for i := range a {
+		a[i] *= b[i]
+	}
+	return a
+}
+
+// Wavelengths are the wavelengths in nm a path carries, with the density
+// each was sampled with. The first is the hero wavelength: when the path
+// meets something dispersive the others are dropped and it carries on alone.
+type Wavelengths struct {
+	Lambda [N_spectrum_samples]float64
+	Pdf    [N_spectrum_samples]float64
+}
+
+// Sample_wavelengths picks the hero wavelength from u in [0, 1) and spaces
+// the others evenly after it, all with a density that favours the
+// wavelengths the eye is most sensitive to (Radziszewski et al. 2009)
+func Sample_wavelengths(u float64) Wavelengths {
+
+	var w Wavelengths
+
+	for i := 0; i < N_spectrum_samples; i++ {
+		up := u + float64(i)/N_spectrum_samples
+		if up > 1 {
+			up -= 1
+		}
+		w.Lambda[i] = 538 - 138.888889*math.Atanh(0.85691062-1.82750197*up)
+		w.Pdf[i] = visible_wavelength_pdf(w.Lambda[i])
+	}
+
+	return w
+}
+
+func visible_wavelength_pdf(lambda float64) float64 {
+	if lambda < lambda_min || lambda > lambda_max {
+		return 0
+	}
+	c := math.Cosh(0.0072 * (lambda - 538))
+	return 0.0039398042 / (c * c)
+}
+
+// Hero is the wavelength that survives Terminate_secondary
+func (w *Wavelengths) Hero() float64 {
+	return w.Lambda[0]
+}
+
+// Terminate_secondary drops all but the hero wavelength, which then stands
+// in for all of them
+func (w *Wavelengths) Terminate_secondary() {
+	if w.Secondary_terminated() {
+		return
+	}
+	for i := 1; i < N_spectrum_samples; i++ {
+		w.Pdf[i] = 0
+	}
+	w.Pdf[0] /= N_spectrum_samples
+}
+
+func (w *Wavelengths) Secondary_terminated() bool {
+	for i := 1; i < N_spectrum_samples; i++ {
+		if w.Pdf[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Upsample evaluates a smooth spectrum with the given RGB color at the
+// wavelengths, using the method of Smits (1999). White gives a flat
+// spectrum and colors within [0, 1] give reflectances within [0, 1].
+func (w *Wavelengths) Upsample(c Color) Sampled_spectrum {
+
+	var s Sampled_spectrum
+	for i := range s {
+		s[i] = rgb_to_spectrum(c, w.Lambda[i])
+	}
+	return s
+}
+
+// To_rgb converts light carried at the wavelengths to linear RGB
+func (w *Wavelengths) To_rgb(s Sampled_spectrum) Color {
+
+	xyz := NewColor(0, 0, 0)
+	for i := range s {
+		if w.Pdf[i] == 0 {
+			continue
+		}
+		xyz = xyz.Add(cie_xyz(w.Lambda[i]).Mult(s[i] / w.Pdf[i]))
+	}
+	xyz = xyz.Div(N_spectrum_samples * cie_y_integral)
+
+	return ComponentMultiply(Xyz_to_rgb(xyz), spectral_white_balance)
+}
+
+// cie_xyz is the multi-lobe fit of the CIE 1931 color matching functions by
+// Wyman et al. (2013)
+func cie_xyz(lambda float64) Color {
+
+	lobe := func(mu float64, sigma_low float64, sigma_high float64) float64 {
+		sigma := sigma_low
+		if lambda >= mu {
+			sigma = sigma_high
+		}
+		t := (lambda - mu) / sigma
+		return math.Exp(-0.5 * t * t)
+	}
+
+	x := 1.056*lobe(599.8, 37.9, 31.0) + 0.362*lobe(442.0, 16.0, 26.7) - 0.065*lobe(501.1, 20.4, 26.2)
+	y := 0.821*lobe(568.8, 46.9, 40.5) + 0.286*lobe(530.9, 16.3, 31.1)
+	z := 1.217*lobe(437.0, 11.8, 36.0) + 0.681*lobe(459.0, 26.0, 13.8)
+
+	return NewColor(x, y, z)
+}
+
+// cie_y_integral normalises the Y of a flat spectrum of 1 to 1
+const cie_y_integral = 106.856895
+
+// Xyz_to_rgb converts CIE XYZ to linear sRGB
+func Xyz_to_rgb(xyz Color) Color {
+	x, y, z := xyz.X(), xyz.Y(), xyz.Z()
+	return NewColor(
+		3.2404542*x-1.5371385*y-0.4985314*z,
+		-0.9692660*x+1.8760108*y+0.0415560*z,
+		0.0556434*x-0.2040259*y+1.0572252*z,
+	)
+}
+
+// spectral_white_balance maps a flat spectrum, which sRGB sees as slightly
+// pink, to white, so spectral renders match RGB ones
+var spectral_white_balance = func() Color {
+
+	xyz := NewColor(0, 0, 0)
+	for lambda := lambda_min; lambda <= lambda_max; lambda++ {
+		xyz = xyz.Add(cie_xyz(lambda))
+	}
+	rgb := Xyz_to_rgb(xyz.Div(cie_y_integral))
+
+	return NewColor(1/rgb.X(), 1/rgb.Y(), 1/rgb.Z())
+}()
+
+// Smits' basis spectra, sampled at 10 wavelengths evenly spread over
+// 380-720 nm
+var (
+	smits_white   = [10]float64{1.0000, 1.0000, 0.9999, 0.9993, 0.9992, 0.9998, 1.0000, 1.0000, 1.0000, 1.0000}
+	smits_cyan    = [10]float64{0.9710, 0.9426, 1.0007, 1.0007, 1.0007, 1.0007, 0.1564, 0.0000, 0.0000, 0.0000}
+	smits_magenta = [10]float64{1.0000, 1.0000, 0.9685, 0.2229, 0.0000, 0.0458, 0.8369, 1.0000, 1.0000, 0.9959}
+	smits_yellow  = [10]float64{0.0001, 0.0000, 0.1088, 0.6651, 1.0000, 1.0000, 0.9996, 0.9586, 0.9685, 0.9840}
+	smits_red     = [10]float64{0.1012, 0.0515, 0.0000, 0.0000, 0.0000, 0.0000, 0.8325, 1.0149, 1.0149, 1.0149}
+	smits_green   = [10]float64{0.0000, 0.0000, 0.0273, 0.7937, 1.0000, 0.9418, 0.1719, 0.0000, 0.0000, 0.0025}
+	smits_blue    = [10]float64{1.0000, 1.0000, 0.8916, 0.3323, 0.0000, 0.0000, 0.0003, 0.0369, 0.0483, 0.0496}
+)
+
+// smits_value interpolates a basis spectrum at lambda, holding the end
+// values outside the range
+func smits_value(basis *[10]float64, lambda float64) float64 {
+
+	t := (lambda - 380) / (720 - 380) * 9
+	if t <= 0 {
+		return basis[0]
+	}
+	if t >= 9 {
+		return basis[9]
+	}
+
+	i := int(t)
+	f := t - float64(i)
+	return basis[i]*(1-f) + basis[i+1]*f
+}
+
+// rgb_to_spectrum adds up white and the basis spectra between the smallest
+// and the other two components of c
+func rgb_to_spectrum(c Color, lambda float64) float64 {
+
+	r, g, b := c.X(), c.Y(), c.Z()
+	at := func(basis *[10]float64) float64 {
+		return smits_value(basis, lambda)
+	}
+
+	switch {
+	case r <= g && r <= b:
+		if g <= b {
+			return r*at(&smits_white) + (g-r)*at(&smits_cyan) + (b-g)*at(&smits_blue)
+		}
+		return r*at(&smits_white) + (b-r)*at(&smits_cyan) + (g-b)*at(&smits_green)
+	case g <= r && g <= b:
+		if r <= b {
+			return g*at(&smits_white) + (r-g)*at(&smits_magenta) + (b-r)*at(&smits_blue)
+		}
+		return g*at(&smits_white) + (b-g)*at(&smits_magenta) + (r-b)*at(&smits_red)
+	default:
+		if r <= g {
+			return b*at(&smits_white) + (r-b)*at(&smits_yellow) + (g-r)*at(&smits_green)
+		}
+		return b*at(&smits_white) + (g-b)*at(&smits_yellow) + (r-g)*at(&smits_red)
+	}
+}
@@ -143,3 +143,27 @@ func Refract(uv Vec3, n Vec3, etai_over_etat float64) Vec3 {
 	r_out_parallel := n.Mult(math.Sqrt(math.Abs(1.0-r_out_perp.Length_squared())) * -1)
 	return r_out_parallel.Add(r_out_perp)
 }
+
+func Random_cosine_direction() Vec3 {
+	r1 := rand.Float64()
+	r2 := rand.Float64()
+
+	phi := 2 * Pi * r1
+	x := math.Cos(phi) * math.Sqrt(r2)
+	y := math.Sin(phi) * math.Sqrt(r2)
+	z := math.Sqrt(1 - r2)
+
+	return NewVec3(x, y, z)
+}
+
+func Random_to_sphere(radius float64, distance_squared float64) Vec3 {
+	r1 := rand.Float64()
+	r2 := rand.Float64()
+	z := 1 + r2*(math.Sqrt(1-radius*radius/distance_squared)-1)
+
+	phi := 2 * Pi * r1
+	x := math.Cos(phi) * math.Sqrt(1-z*z)
+	y := math.Sin(phi) * math.Sqrt(1-z*z)
+
+	return NewVec3(x, y, z)
+}
@@ -15,15 +15,55 @@ func main() {
 
 	world, cam = scenes.RandomSpheres()
 	// world, cam = scenes.Planet()
+	// world, cam = scenes.PlanetNebula()
 	// world, cam = scenes.Earth()
 
 	// world, cam = scenes.Quads()
 
 	// world, cam = scenes.Boxes()
+	// world, cam = scenes.Smoke()
 
 	// world, cam = scenes.Meshes()
+	// world, cam = scenes.Wax()
+
+	// world, cam = scenes.Spotlights()
+	// world, cam = scenes.Materials()
+	// world, cam = scenes.Glass()
+	// world, cam = scenes.Mixes()
+
+	// Daylight sky and sun in place of the Background color
+	// sky := NewSky_light(40, 135, 3)
+	// cam.Environment = &sky
+
+	// Sun over London on a summer afternoon
+	// sun := Solar_position(51.5, -0.13, time.Date(2024, 6, 21, 16, 0, 0, 0, time.FixedZone("BST", 3600)))
+	// sky := sun.Sky_light(3)
+	// cam.Environment = &sky
+
+	// Debug views: Normals_integrator, Facing_integrator, Uv_integrator,
+	// Barycentric_integrator, Depth_integrator, Bvh_heatmap
+	// cam.Integrator = &Bvh_heatmap{Max_count: 64}
+	// cam.Sample_per_pixel = 4
+
+	// Dispersion, following a few wavelengths per path instead of RGB
+	// cam.Integrator = &Spectral_path_tracer{}
+
+	// Clay preview
+	// ao := NewAo_integrator(16, 100)
+	// cam.Integrator = &ao
 
 	bvh := NewBvh(world.Objects)
 	cam.RenderMultithreaded(&bvh)
+	// cam.RenderBidirectional(&bvh)
+	// cam.RenderPhotonMap(&bvh, 1000000, 5)
+	// cam.RenderSPPM(&bvh, 256, 200000, 20)
+
+	// Convergence animation, one frame per snapshot of the accumulated image
+	// frames := cam.RenderConvergence(&bvh, 15)
+	// file, _ := os.Create("convergence.png")
+	// Encode_apng(file, frames, 20)
+	// file.Close()
+
+	// Russian roulette benchmarks: go test ./scenes -bench Roulette
 
 }
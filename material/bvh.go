@@ -18,7 +18,7 @@ func NewBvh(objects []Hittable) Bvh {
 func NewBvhNode(objects []Hittable, start int, end int) Bvh {
 
 	bvh := Bvh{}
-	bvh.bbox = Aabb{}
+	bvh.bbox = NewAabb(Empty, Empty, Empty)
 
 	for object_index := start; object_index < end; object_index++ {
 		bvh.bbox = Merge(bvh.bbox, objects[object_index].Bounding_box())
@@ -66,8 +66,16 @@ func (bvh Bvh) Bounding_box() Aabb {
 	return bvh.bbox
 }
 
+func (bvh Bvh) Children() []Hittable {
+	return []Hittable{bvh.left, bvh.right}
+}
+
 func (bvh Bvh) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 
+	if r.Stats != nil {
+		r.Stats.Nodes++
+	}
+
 	if !bvh.bbox.Hit(r, &ray_t) {
 		return false
 	}
@@ -85,6 +93,21 @@ func (bvh Bvh) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 
 }
 
+// Occluded can stop at the first child that blocks the ray
+func (bvh Bvh) Occluded(r *Ray, ray_t Interval) bool {
+
+	if r.Stats != nil {
+		r.Stats.Nodes++
+	}
+
+	if !bvh.bbox.Hit(r, &ray_t) {
+		return false
+	}
+
+	return bvh.left.Occluded(r, ray_t) || bvh.right.Occluded(r, ray_t)
+
+}
+
 func (bvh *Bvh) box_compare(a Hittable, b Hittable, axis_index int) bool {
 
 	a_box := a.Bounding_box()
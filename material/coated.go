@@ -0,0 +1,404 @@
+package material
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+)
+
+// coated_max_depth bounds the bounces between the coat and the base
+const coated_max_depth = 10
+
+// Coated puts a dielectric coat over any base material, like the clear coat
+// of car paint over metallic flakes or varnish over wood. Light reflects off
+// the coat or refracts into it, and may bounce between the base and the
+// underside of the coat several times, tinted by Absorption on each pass,
+// before it leaves again.
+//
+// The light paths inside are followed by random walks (Guo et al. 2018), so
+// Eval returns an unbiased estimate and Pdf an approximation, both varying
+// from call to call. The base should be opaque; paths it transmits are lost.
+type Coated struct {
+	Base       Material
+	Thickness  float64
+	Absorption Color // absorption coefficient per unit distance inside the coat, black when clear
+	ior        float64
+	roughness  float64
+	coat       coat_interface
+}
+
+func NewCoated(base Material, ior float64, roughness float64) Coated {
+	return Coated{
+		Base:      base,
+		Thickness: 0.01,
+		ior:       ior,
+		roughness: roughness,
+		coat:      new_coat_interface(new_ggx(roughness, roughness), ior),
+	}
+}
+
+// coat_interface is the rough boundary of the coat seen from either side
+type coat_interface struct {
+	above microfacet_dielectric
+	below microfacet_dielectric
+}
+
+func new_coat_interface(distribution ggx, eta float64) coat_interface {
+	return coat_interface{
+		above: microfacet_dielectric{distribution: distribution, eta: eta},
+		below: microfacet_dielectric{distribution: distribution, eta: 1 / eta},
+	}
+}
+
+func flip_z(v Vec3) Vec3 {
+	return NewVec3(v.X(), v.Y(), -v.Z())
+}
+
+func (c coat_interface) eval(wo Vec3, wi Vec3) (float64, float64) {
+	if wo.Z() < 0 {
+		return c.below.eval(flip_z(wo), flip_z(wi))
+	}
+	return c.above.eval(wo, wi)
+}
+
+func (c coat_interface) pdf(wo Vec3, wi Vec3) float64 {
+	if wo.Z() < 0 {
+		return c.below.pdf(flip_z(wo), flip_z(wi))
+	}
+	return c.above.pdf(wo, wi)
+}
+
+func (c coat_interface) sample(wo Vec3) (Vec3, bool) {
+	if wo.Z() < 0 {
+		wi, ok := c.below.sample(flip_z(wo))
+		return flip_z(wi), ok
+	}
+	return c.above.sample(wo)
+}
+
+// transmission samples the direction wo refracts into, returning it with the
+// BSDF times cosine over pdf and the pdf
+func (c coat_interface) transmission(wo Vec3) (Vec3, float64, float64, bool) {
+
+	wi, ok := c.sample(wo)
+	if !ok || wi.Z()*wo.Z() >= 0 {
+		return Vec3{}, 0, 0, false
+	}
+
+	pdf := c.pdf(wo, wi)
+	_, transmitted := c.eval(wo, wi)
+	if pdf <= 0 || transmitted <= 0 {
+		return Vec3{}, 0, 0, false
+	}
+
+	return wi, transmitted / pdf, pdf, true
+}
+
+// layer is the coat and base at one hit, in the local frame of its normal
+type layer struct {
+	c     *Coated
+	rec   *Hit_record
+	frame Onb
+}
+
+func (c *Coated) layer(rec *Hit_record) layer {
+	return layer{c: c, rec: rec, frame: NewOnb(rec.Normal)}
+}
+
+// transmittance is the fraction of light crossing the coat along w
+func (l layer) transmittance(w Vec3) Color {
+	if l.c.Absorption.Near_zero() {
+		return NewColor(1, 1, 1)
+	}
+	distance := l.c.Thickness / math.Abs(w.Z())
+	return NewColor(
+		math.Exp(-l.c.Absorption.X()*distance),
+		math.Exp(-l.c.Absorption.Y()*distance),
+		math.Exp(-l.c.Absorption.Z()*distance),
+	)
+}
+
+// base_ray is the ray arriving at the base travelling along w
+func (l layer) base_ray(w Vec3) Ray {
+	return NewRay(l.rec.P, l.frame.Local(w))
+}
+
+// base_eval is the base's BSDF times cosine scattering light arriving along
+// w out along wi
+func (l layer) base_eval(w Vec3, wi Vec3) Color {
+	ray := l.base_ray(w)
+	return l.c.Base.Eval(&ray, l.rec, l.frame.Local(wi))
+}
+
+func (l layer) base_pdf(w Vec3, wi Vec3) float64 {
+	ray := l.base_ray(w)
+	return l.c.Base.Pdf(&ray, l.rec, l.frame.Local(wi))
+}
+
+// base_sample scatters light arriving along w off the base, failing if it
+// goes through
+func (l layer) base_sample(w Vec3) (Vec3, Bsdf_sample, bool) {
+	ray := l.base_ray(w)
+	bs, ok := l.c.Base.Sample(&ray, l.rec)
+	if !ok {
+		return Vec3{}, bs, false
+	}
+	wi := l.frame.To_local(Unit_vector(bs.Direction))
+	return wi, bs, wi.Z() > 0
+}
+
+// coat_roulette ends walks between the layers whose throughput has fallen
+// low, returning the survivor's boost or false
+func coat_roulette(depth int, beta Color) (float64, bool) {
+	max := math.Max(beta.X(), math.Max(beta.Y(), beta.Z()))
+	if depth <= 3 || max >= 0.25 {
+		return 1, true
+	}
+	q := math.Max(0, 1-max)
+	if rand.Float64() < q {
+		return 0, false
+	}
+	return 1 / (1 - q), true
+}
+
+func (c *Coated) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	if !rec.Front_face {
+		return c.Base.Sample(r_in, rec)
+	}
+
+	l := c.layer(rec)
+	wo := l.frame.To_local(Unit_vector(r_in.Direction).Mult(-1))
+
+	w, ok := c.coat.sample(wo)
+	if !ok {
+		return Bsdf_sample{}, false
+	}
+
+	// Reflected by the coat
+	if w.Z() > 0 {
+		pdf := c.coat.pdf(wo, w)
+		reflected, _ := c.coat.eval(wo, w)
+		if pdf <= 0 {
+			return Bsdf_sample{}, false
+		}
+		weight := reflected / pdf
+		direction := l.frame.Local(w)
+		return Bsdf_sample{
+			Direction: direction,
+			Weight:    NewColor(weight, weight, weight),
+			Pdf:       c.Pdf(r_in, rec, direction),
+			Flags:     Bsdf_glossy | Bsdf_reflection,
+		}, true
+	}
+
+	pdf := c.coat.pdf(wo, w)
+	_, transmitted := c.coat.eval(wo, w)
+	if pdf <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	beta := NewColor(1, 1, 1).Mult(transmitted / pdf)
+	lobe := Bsdf_glossy
+
+	// Bounce between the base and the underside of the coat until the path
+	// leaves through the top
+	at_base := false
+	for depth := 0; depth < coated_max_depth; depth++ {
+
+		boost, ok := coat_roulette(depth, beta)
+		if !ok {
+			return Bsdf_sample{}, false
+		}
+		beta = ComponentMultiply(beta, l.transmittance(w)).Mult(boost)
+		at_base = !at_base
+
+		if at_base {
+			wi, bs, ok := l.base_sample(w)
+			if !ok {
+				return Bsdf_sample{}, false
+			}
+			beta = ComponentMultiply(beta, bs.Weight)
+			if bs.Flags&Bsdf_diffuse != 0 {
+				lobe = Bsdf_diffuse
+			}
+			w = wi
+			continue
+		}
+
+		wi, ok := c.coat.sample(w.Mult(-1))
+		if !ok {
+			return Bsdf_sample{}, false
+		}
+		pdf := c.coat.pdf(w.Mult(-1), wi)
+		reflected, transmitted := c.coat.eval(w.Mult(-1), wi)
+		if pdf <= 0 {
+			return Bsdf_sample{}, false
+		}
+		beta = beta.Mult((reflected + transmitted) / pdf)
+
+		if wi.Z() > 0 {
+			direction := l.frame.Local(wi)
+			return Bsdf_sample{
+				Direction: direction,
+				Weight:    beta,
+				Pdf:       c.Pdf(r_in, rec, direction),
+				Flags:     lobe | Bsdf_reflection,
+			}, true
+		}
+		w = wi
+	}
+
+	return Bsdf_sample{}, false
+}
+
+// Eval adds the reflection off the coat to an estimate of the light
+// refracted in and out of it along one random walk. At each bounce off the
+// base, light leaving towards wi is found both by refracting wi into the
+// coat and by scattering off the base, combined with the power heuristic.
+func (c *Coated) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+
+	if !rec.Front_face {
+		return c.Base.Eval(r_in, rec, direction)
+	}
+
+	l := c.layer(rec)
+	wo := l.frame.To_local(Unit_vector(r_in.Direction).Mult(-1))
+	wi := l.frame.To_local(Unit_vector(direction))
+
+	if wo.Z() <= 0 || wi.Z() <= 0 {
+		return NewColor(0, 0, 0)
+	}
+
+	reflected, _ := c.coat.eval(wo, wi)
+	f := NewColor(reflected, reflected, reflected)
+
+	w, beta_o, _, ok := c.coat.transmission(wo)
+	if !ok {
+		return f
+	}
+
+	// Light refracted in along wis leaves along wi with this weight, the
+	// BSDF of the coat for wi over the pdf of sampling wis. The BSDF is
+	// found going in, so the change in radiance the interface leaves out
+	// has to be undone for light coming out.
+	wis, beta_i, pdf_i, refracts := c.coat.transmission(wi)
+	exit_weight := 0.0
+	if refracts {
+		exit_weight = beta_i * wi.Z() / (math.Abs(wis.Z()) * c.ior * c.ior)
+	}
+
+	beta := NewColor(1, 1, 1).Mult(beta_o)
+	base_is_delta := c.Base.Flags().Is_delta()
+
+	at_base := false
+	for depth := 0; depth < coated_max_depth; depth++ {
+
+		boost, ok := coat_roulette(depth, beta)
+		if !ok {
+			break
+		}
+		beta = ComponentMultiply(beta, l.transmittance(w)).Mult(boost)
+		at_base = !at_base
+
+		if !at_base {
+			// Reflect off the underside of the coat
+			wr, ok := c.coat.sample(w.Mult(-1))
+			if !ok || wr.Z() > 0 {
+				break
+			}
+			pdf := c.coat.pdf(w.Mult(-1), wr)
+			reflected, _ := c.coat.eval(w.Mult(-1), wr)
+			if pdf <= 0 {
+				break
+			}
+			beta = beta.Mult(reflected / pdf)
+			w = wr
+			continue
+		}
+
+		// Light scattered off the base straight up along wis
+		if refracts && !base_is_delta {
+			up := wis.Mult(-1)
+			weight := Power_heuristic(pdf_i, l.base_pdf(w, up))
+			through := ComponentMultiply(l.base_eval(w, up), l.transmittance(wis))
+			f = f.Add(ComponentMultiply(beta, through).Mult(weight * exit_weight))
+		}
+
+		wb, bs, ok := l.base_sample(w)
+		if !ok {
+			break
+		}
+		beta = ComponentMultiply(beta, bs.Weight)
+		w = wb
+
+		// Light scattered off the base in a sampled direction that
+		// refracts out along wi
+		_, exit := c.coat.eval(w.Mult(-1), wi)
+		if exit > 0 {
+			weight := 1.0
+			if bs.Flags&Bsdf_specular == 0 {
+				weight = Power_heuristic(bs.Pdf, c.coat.pdf(wi, w.Mult(-1)))
+			}
+			through := ComponentMultiply(beta, l.transmittance(w))
+			f = f.Add(through.Mult(exit * weight))
+		}
+	}
+
+	return f
+}
+
+// Pdf estimates the density of Sample from one refraction into the coat
+// and one out, mixed with a uniform density so that no direction Sample may
+// return is given a density of zero
+func (c *Coated) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+
+	if !rec.Front_face {
+		return c.Base.Pdf(r_in, rec, direction)
+	}
+
+	l := c.layer(rec)
+	wo := l.frame.To_local(Unit_vector(r_in.Direction).Mult(-1))
+	wi := l.frame.To_local(Unit_vector(direction))
+
+	if wo.Z() <= 0 || wi.Z() <= 0 {
+		return 0
+	}
+
+	pdf := c.coat.pdf(wo, wi)
+
+	w, _, _, enters := c.coat.transmission(wo)
+	if !enters {
+		return 0.1/(4*Pi) + 0.9*pdf
+	}
+
+	wb, bs, ok := l.base_sample(w)
+	if !ok {
+		return 0.1/(4*Pi) + 0.9*pdf
+	}
+
+	exit_pdf := c.coat.pdf(wb.Mult(-1), wi)
+	wis, _, pdf_i, refracts := c.coat.transmission(wi)
+
+	if !refracts || c.Base.Flags().Is_delta() {
+		pdf += exit_pdf
+	} else {
+		// The base's density is over directions inside the coat, which
+		// refraction spreads over more solid angle outside
+		base_pdf := l.base_pdf(w, wis.Mult(-1))
+		pdf += Power_heuristic(pdf_i, base_pdf) * base_pdf * wi.Z() / (math.Abs(wis.Z()) * c.ior * c.ior)
+		pdf += Power_heuristic(bs.Pdf, c.coat.pdf(wi, wb.Mult(-1))) * exit_pdf
+	}
+
+	return 0.1/(4*Pi) + 0.9*pdf
+}
+
+func (c *Coated) Flags() Bsdf_flags {
+	return c.Base.Flags()&Bsdf_diffuse | Bsdf_glossy | Bsdf_reflection
+}
+
+// Emitted lets the base's emission through the coat unchanged
+func (c *Coated) Emitted(u float64, v float64, p *Point3) Color {
+	return c.Base.Emitted(u, v, p)
+}
@@ -0,0 +1,179 @@
+package material
+
+import (
+	"math"
+	"math/cmplx"
+
+	. "raytracer/common"
+)
+
+// Complex_ior is the refractive index N + iK of a metal at the red, green
+// and blue wavelengths (650, 550 and 450 nm), K being the absorption
+type Complex_ior struct {
+	N, K Color
+}
+
+var (
+	Gold      = Complex_ior{N: NewColor(0.143, 0.374, 1.442), K: NewColor(3.983, 2.386, 1.603)}
+	Copper    = Complex_ior{N: NewColor(0.200, 0.924, 1.102), K: NewColor(3.912, 2.452, 2.142)}
+	Silver    = Complex_ior{N: NewColor(0.155, 0.117, 0.138), K: NewColor(4.828, 3.122, 2.147)}
+	Aluminium = Complex_ior{N: NewColor(1.657, 0.880, 0.521), K: NewColor(9.224, 6.270, 4.837)}
+	Chrome    = Complex_ior{N: NewColor(3.180, 3.180, 2.240), K: NewColor(3.300, 3.330, 3.160)}
+)
+
+// Conductor is a metal with a GGX microfacet surface. Unlike Metal's fuzz it
+// conserves energy and never scatters below the surface, and its color comes
+// from the Fresnel reflectance of a complex IOR, which tints towards white
+// at grazing angles as real metals do.
+//
+// Anisotropic roughness stretches highlights as on brushed metal. The first
+// roughness runs along the surface's horizontal tangent (around the y axis),
+// the second across it. A roughness of zero is a perfect mirror.
+type Conductor struct {
+	Ior          Complex_ior
+	roughness_u  float64
+	roughness_v  float64
+	distribution ggx
+}
+
+func NewConductor(ior Complex_ior, roughness float64) Conductor {
+	return NewAnisotropic_conductor(ior, roughness, roughness)
+}
+
+func NewAnisotropic_conductor(ior Complex_ior, roughness_u float64, roughness_v float64) Conductor {
+	return Conductor{
+		Ior:          ior,
+		roughness_u:  roughness_u,
+		roughness_v:  roughness_v,
+		distribution: new_ggx(roughness_u, roughness_v),
+	}
+}
+
+func (c *Conductor) smooth() bool {
+	return c.roughness_u <= 0 && c.roughness_v <= 0
+}
+
+// frame is the shading frame, with x along the horizontal tangent
+func (c *Conductor) frame(rec *Hit_record) Onb {
+	return tangent_frame(rec.Normal)
+}
+
+func (c *Conductor) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	frame := c.frame(rec)
+	wo := frame.To_local(Unit_vector(r_in.Direction).Mult(-1))
+	if wo.Z() <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	if c.smooth() {
+		return Bsdf_sample{
+			Direction: frame.Local(NewVec3(-wo.X(), -wo.Y(), wo.Z())),
+			Weight:    fresnel_conductor(wo.Z(), c.Ior),
+			Flags:     Bsdf_specular | Bsdf_reflection,
+		}, true
+	}
+
+	m := c.distribution.sample_visible(wo)
+	wi := reflect_local(wo, m)
+	if wi.Z() <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	pdf := c.pdf(wo, wi)
+	if pdf <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	return Bsdf_sample{
+		Direction: frame.Local(wi),
+		Weight:    c.eval(wo, wi).Div(pdf),
+		Pdf:       pdf,
+		Flags:     Bsdf_glossy | Bsdf_reflection,
+	}, true
+}
+
+func (c *Conductor) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	if c.smooth() {
+		return NewColor(0, 0, 0)
+	}
+	frame := c.frame(rec)
+	return c.eval(frame.To_local(Unit_vector(r_in.Direction).Mult(-1)), frame.To_local(Unit_vector(direction)))
+}
+
+func (c *Conductor) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	if c.smooth() {
+		return 0
+	}
+	frame := c.frame(rec)
+	return c.pdf(frame.To_local(Unit_vector(r_in.Direction).Mult(-1)), frame.To_local(Unit_vector(direction)))
+}
+
+// eval is the BRDF times the cosine, D G F / (4 cos_o)
+func (c *Conductor) eval(wo Vec3, wi Vec3) Color {
+	if wo.Z() <= 0 || wi.Z() <= 0 {
+		return NewColor(0, 0, 0)
+	}
+	m := Unit_vector(wo.Add(wi))
+	f := fresnel_conductor(Dot(wo, m), c.Ior)
+	return f.Mult(c.distribution.d(m) * c.distribution.g(wo, wi) / (4 * wo.Z()))
+}
+
+// pdf of reflecting a visible normal sample
+func (c *Conductor) pdf(wo Vec3, wi Vec3) float64 {
+	if wo.Z() <= 0 || wi.Z() <= 0 {
+		return 0
+	}
+	m := Unit_vector(wo.Add(wi))
+	return c.distribution.pdf_visible(wo, m) / (4 * Dot(wo, m))
+}
+
+func (c *Conductor) Flags() Bsdf_flags {
+	if c.smooth() {
+		return Bsdf_specular | Bsdf_reflection
+	}
+	return Bsdf_glossy | Bsdf_reflection
+}
+
+func (c *Conductor) Emitted(u float64, v float64, p *Point3) Color {
+	return NewColor(0, 0, 0)
+}
+
+// fresnel_conductor is the unpolarized reflectance of a metal for light
+// arriving at cos_i, per color channel
+func fresnel_conductor(cos_i float64, ior Complex_ior) Color {
+
+	n := ior.N.XYZ()
+	k := ior.K.XYZ()
+
+	cos_i = math.Max(0, math.Min(1, cos_i))
+	sin2_i := complex(1-cos_i*cos_i, 0)
+	cos := complex(cos_i, 0)
+
+	var r [3]float64
+	for i := range r {
+		eta := complex(n[i], k[i])
+		cos_t := cmplx.Sqrt(1 - sin2_i/(eta*eta))
+
+		r_parallel := (eta*cos - cos_t) / (eta*cos + cos_t)
+		r_perpendicular := (cos - eta*cos_t) / (cos + eta*cos_t)
+
+		r[i] = (norm(r_parallel) + norm(r_perpendicular)) / 2
+	}
+
+	return NewColor(r[0], r[1], r[2])
+}
+
+func norm(z complex128) float64 {
+	return real(z)*real(z) + imag(z)*imag(z)
+}
+
+// tangent_frame is a shading frame whose x axis runs horizontally around
+// the y axis, falling back to x where the normal points straight up or down
+func tangent_frame(n Vec3) Onb {
+	t := Cross(NewVec3(0, 1, 0), n)
+	if t.Length_squared() < 1e-8 {
+		t = NewVec3(1, 0, 0)
+	}
+	return NewOnbTangent(n, t)
+}
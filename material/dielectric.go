@@ -6,23 +6,66 @@ import (
 	. "raytracer/common"
 )
 
+// Dielectric is smooth glass. Light travelling through it is attenuated by
+// Absorption following the Beer-Lambert law, which tints thick parts more
+// than thin ones; this needs a closed object with outward facing normals.
+// Absorption and Priority are ignored by bidirectional path tracing, see
+// Interior.
 type Dielectric struct {
-	ir float64
+	ir         float64
+	Absorption Color     // absorption coefficient per unit distance, black for clear glass
+	Priority   int       // decides which medium fills the overlap of nested objects, see Nested
+	Dispersion Ior_curve // IOR per wavelength when rendering spectrally, constant when nil
 }
 
 func NewDielectric(index_of_refraction float64) Dielectric {
 	return Dielectric{ir: index_of_refraction}
 }
 
-func (d *Dielectric) Scatter(r *Ray, rec *Hit_record, attenuation *Color, scattered *Ray) bool {
+// NewTinted_dielectric makes colored glass that lets through color of the
+// light after travelling depth inside it. A depth of zero or less gives
+// clear glass.
+func NewTinted_dielectric(index_of_refraction float64, color Color, depth float64) Dielectric {
+	return Dielectric{ir: index_of_refraction, Absorption: absorption_for(color, depth)}
+}
+
+// NewDispersive_dielectric makes glass whose IOR depends on the wavelength,
+// which shows as colored fringes when rendering spectrally. Elsewhere it
+// refracts with its IOR at the d line.
+func NewDispersive_dielectric(dispersion Ior_curve) Dielectric {
+	return Dielectric{ir: dispersion.Ior(d_line), Dispersion: dispersion}
+}
+
+// absorption_for finds the absorption coefficient that transmits color
+// over depth, or none for a depth that is not positive
+func absorption_for(color Color, depth float64) Color {
+	if depth <= 0 {
+		return NewColor(0, 0, 0)
+	}
+
+	c := color.XYZ()
+	var sigma_a [3]float64
+	for i := 0; i < 3; i++ {
+		sigma_a[i] = -math.Log(math.Max(1e-6, math.Min(1, c[i]))) / depth
+	}
+	return NewColor(sigma_a[0], sigma_a[1], sigma_a[2])
+}
+
+func (d *Dielectric) Sample(r *Ray, rec *Hit_record) (Bsdf_sample, bool) {
 
-	*attenuation = NewColor(1.0, 1.0, 1.0)
 	var refraction_ratio float64
+	var lobe Bsdf_flags
+
+	ir := d.ir
+	if d.Dispersion != nil && r.Wavelength > 0 {
+		ir = d.Dispersion.Ior(r.Wavelength)
+		lobe = Bsdf_dispersive
+	}
 
 	if rec.Front_face {
-		refraction_ratio = 1.0 / d.ir
+		refraction_ratio = outside_ior(rec) / ir
 	} else {
-		refraction_ratio = d.ir
+		refraction_ratio = ir / outside_ior(rec)
 	}
 
 	unit_direction := Unit_vector(r.Direction)
@@ -36,17 +79,33 @@ func (d *Dielectric) Scatter(r *Ray, rec *Hit_record, attenuation *Color, scatte
 
 	if cannot_refract || reflectance(cos_theta, refraction_ratio) > rand.Float64() {
 		direction = Reflect(unit_direction, rec.Normal)
+		lobe |= Bsdf_reflection
 	} else {
 		direction = Refract(unit_direction, rec.Normal, refraction_ratio)
+		lobe |= Bsdf_transmission
 	}
 
 	// refracted := Refract(unit_direction, rec.Normal, refraction_ratio)
 
-	*scattered = NewRay(rec.P, direction)
-	return true
+	return Bsdf_sample{Direction: direction, Weight: NewColor(1.0, 1.0, 1.0), Flags: Bsdf_specular | lobe}, true
 
 }
 
+func (d *Dielectric) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	return NewColor(0, 0, 0)
+}
+
+func (d *Dielectric) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	return 0
+}
+
+func (d *Dielectric) Flags() Bsdf_flags {
+	if d.Dispersion != nil {
+		return Bsdf_specular | Bsdf_reflection | Bsdf_transmission | Bsdf_dispersive
+	}
+	return Bsdf_specular | Bsdf_reflection | Bsdf_transmission
+}
+
 func reflectance(cosine float64, ref_idx float64) float64 {
 	r0 := (1 - ref_idx) / (1 + ref_idx)
 	r0 = r0 * r0
@@ -56,3 +115,16 @@ func reflectance(cosine float64, ref_idx float64) float64 {
 func (d *Dielectric) Emitted(u float64, v float64, p *Point3) Color {
 	return NewColor(0, 0, 0)
 }
+
+// Interior is a medium that absorbs but never scatters
+func (d *Dielectric) Interior(rec *Hit_record) Medium {
+	return Medium{Sigma_t: d.Absorption}
+}
+
+func (d *Dielectric) Index_of_refraction() float64 {
+	return d.ir
+}
+
+func (d *Dielectric) Medium_priority() int {
+	return d.Priority
+}
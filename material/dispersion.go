@@ -0,0 +1,48 @@
+package material
+
+import (
+	"math"
+)
+
+// Ior_curve gives the index of refraction of a material at a wavelength in
+// nm, making refraction split white light into its colors
+type Ior_curve interface {
+	Ior(wavelength float64) float64
+}
+
+// Cauchy is the empirical fit n = A + B / wavelength^2, with the wavelength
+// in micrometres. It is good enough for most glasses in the visible range.
+type Cauchy struct {
+	A, B float64
+}
+
+func (c Cauchy) Ior(wavelength float64) float64 {
+	um := wavelength / 1000
+	return c.A + c.B/(um*um)
+}
+
+// Sellmeier is the fit n^2 = 1 + sum B_i wavelength^2 / (wavelength^2 - C_i)
+// from glass catalogues, with the wavelength in micrometres
+type Sellmeier struct {
+	B [3]float64
+	C [3]float64
+}
+
+func (s Sellmeier) Ior(wavelength float64) float64 {
+	um2 := wavelength * wavelength / 1e6
+	n2 := 1.0
+	for i := 0; i < 3; i++ {
+		n2 += s.B[i] * um2 / (um2 - s.C[i])
+	}
+	return math.Sqrt(n2)
+}
+
+// Glass presets
+var (
+	BK7          = Sellmeier{B: [3]float64{1.03961212, 0.231792344, 1.01046945}, C: [3]float64{0.00600069867, 0.0200179144, 103.560653}}
+	Fused_silica = Sellmeier{B: [3]float64{0.6961663, 0.4079426, 0.8974794}, C: [3]float64{0.00467914826, 0.0135120631, 97.9340025}}
+	Diamond      = Sellmeier{B: [3]float64{0.3306, 4.3356, 0}, C: [3]float64{0.030625, 0.011236, 0}}
+)
+
+// d_line is the wavelength in nm at which glasses quote their IOR
+const d_line = 587.6
@@ -5,19 +5,48 @@ import (
 )
 
 type Hit_record struct {
-	P          Point3
-	Normal     Vec3
-	Mat        *Material
-	T          float64
-	U, V       float64
-	Front_face bool
+	P           Point3
+	Normal      Vec3
+	Mat         *Material
+	T           float64
+	U, V        float64
+	Front_face  bool
+	Object      Hittable // primitive that was hit, used to recognise sampled lights
+	Outside_ior float64  // IOR of the medium on the other side of a dielectric's surface, zero for air
+	lit_by      []Hittable
+	linked      Hittable
+}
+
+// Link_lights limits the lights that illuminate the hit to the given ones,
+// for as long as the record holds the current Object
+func (h *Hit_record) Link_lights(lights []Hittable) {
+	h.lit_by = lights
+	h.linked = h.Object
+}
+
+// Lit_by returns the only lights that illuminate the hit, or nil when every
+// light does. A closer hit on another object drops the link.
+func (h *Hit_record) Lit_by() []Hittable {
+	if h.linked != h.Object {
+		return nil
+	}
+	return h.lit_by
 }
 
 type Hittable interface {
 	Hit(r *Ray, ray_t Interval, rec *Hit_record) bool
+	// Occluded reports whether anything is hit within ray_t, stopping at the
+	// first hit found rather than searching for the closest
+	Occluded(r *Ray, ray_t Interval) bool
 	Bounding_box() Aabb
 }
 
+// Aggregate is implemented by hittables that group other hittables, allowing
+// the scene graph to be walked (e.g. to find emissive objects)
+type Aggregate interface {
+	Children() []Hittable
+}
+
 func (h *Hit_record) Set_face_normal(r *Ray, outward_normal Vec3) {
 	h.Front_face = Dot(r.Direction, outward_normal) < 0
 	if h.Front_face {
@@ -65,6 +94,22 @@ func (l Hittable_list) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 
 }
 
+func (l Hittable_list) Occluded(r *Ray, ray_t Interval) bool {
+
+	for _, object := range l.Objects {
+		if object.Occluded(r, ray_t) {
+			return true
+		}
+	}
+
+	return false
+
+}
+
 func (l Hittable_list) Bounding_box() Aabb {
 	return l.bbox
 }
+
+func (l Hittable_list) Children() []Hittable {
+	return l.Objects
+}
@@ -0,0 +1,45 @@
+package material
+
+import (
+	. "raytracer/common"
+)
+
+// Medium is the homogeneous interior of a closed object. Paths transmitted
+// into the object travel through it, being attenuated and scattered, until
+// they reach the surface again.
+type Medium struct {
+	Sigma_t Color // extinction coefficient per channel, 1 / mean free path
+	Albedo  Color // chance of scattering rather than being absorbed at each collision
+}
+
+// Interior is implemented by materials whose objects are filled with a
+// medium, entered when a path is transmitted through the front face.
+// The path tracer, photon mapping and SPPM follow paths through media and
+// honour Nested priorities; bidirectional path tracing connects its subpaths
+// with straight shadow rays and sees every filled object as clear and
+// unnested.
+type Interior interface {
+	Interior(rec *Hit_record) Medium
+}
+
+// Nested is implemented by dielectrics that can be placed inside or across
+// each other, like ice floating in water in a glass. Where their objects
+// overlap the one with the highest Priority fills the space and the surfaces
+// of the others are ignored, so the overlap can be modelled generously
+// instead of with exactly matching surfaces. A negative priority marks a
+// material, like a Mix, that is only sometimes nested and is not this time:
+// its surfaces are never ignored and it fills space like any other Interior.
+type Nested interface {
+	Interior
+	Index_of_refraction() float64
+	Medium_priority() int
+}
+
+// outside_ior is the IOR beyond the surface of a hit, air unless the
+// renderer found the object nested in another
+func outside_ior(rec *Hit_record) float64 {
+	if rec.Outside_ior > 0 {
+		return rec.Outside_ior
+	}
+	return 1
+}
@@ -19,25 +19,46 @@ func NewTexturedLambertian(tex Texture) Lambertian {
 	return Lambertian{tex: tex}
 }
 
-func (l *Lambertian) Scatter(r *Ray, rec *Hit_record, attenuation *Color, scattered *Ray) bool {
+func (l *Lambertian) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
 	scatter_direction := rec.Normal.Add(Random_unit_vector())
 
 	if scatter_direction.Near_zero() {
 		scatter_direction = rec.Normal
 	}
 
-	*scattered = NewRay(rec.P, scatter_direction)
+	// Cosine weighted sampling cancels the cosine and 1/pi of the BRDF
+	return Bsdf_sample{
+		Direction: scatter_direction,
+		Weight:    l.tex.Value(rec.U, rec.V, &rec.P),
+		Pdf:       l.Pdf(r_in, rec, scatter_direction),
+		Flags:     l.Flags(),
+	}, true
 
-	// *attenuation = l.Albedo
+}
 
-	*attenuation = l.tex.Value(rec.U, rec.V, &rec.P)
+func (l *Lambertian) Emitted(u float64, v float64, p *Point3) Color {
+	return NewColor(0, 0, 0)
+}
 
-	return true
+func (l *Lambertian) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	cosine := Dot(Unit_vector(direction), rec.Normal)
+	if cosine <= 0 {
+		return NewColor(0, 0, 0)
+	}
+	return l.tex.Value(rec.U, rec.V, &rec.P).Mult(cosine / Pi)
+}
 
+// Pdf of Sample, which picks cosine weighted directions around the normal
+func (l *Lambertian) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	cosine := Dot(Unit_vector(direction), rec.Normal)
+	if cosine <= 0 {
+		return 0
+	}
+	return cosine / Pi
 }
 
-func (l *Lambertian) Emitted(u float64, v float64, p *Point3) Color {
-	return NewColor(0, 0, 0)
+func (l *Lambertian) Flags() Bsdf_flags {
+	return Bsdf_diffuse | Bsdf_reflection
 }
 
 type Diffuse_light struct {
@@ -48,8 +69,22 @@ type Diffuse_light struct {
 func (l *Diffuse_light) Emitted(u float64, v float64, p *Point3) Color {
 	return l.tex.Value(u, v, p).Mult(l.intensity)
 }
-func (l *Diffuse_light) Scatter(r *Ray, rec *Hit_record, attenuation *Color, scattered *Ray) bool {
-	return false
+
+// Lights absorb everything that reaches them
+func (l *Diffuse_light) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+	return Bsdf_sample{}, false
+}
+
+func (l *Diffuse_light) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	return NewColor(0, 0, 0)
+}
+
+func (l *Diffuse_light) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	return 0
+}
+
+func (l *Diffuse_light) Flags() Bsdf_flags {
+	return Bsdf_none
 }
 
 func NewDiffuse_light(c Color) Diffuse_light {
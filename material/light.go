@@ -0,0 +1,23 @@
+package material
+
+import (
+	. "raytracer/common"
+)
+
+// Light_sample is a direction towards a light chosen by Light.Sample_li
+type Light_sample struct {
+	Direction Vec3    // unit vector from the shaded point towards the light
+	Distance  float64 // distance to the sampled point on the light
+	Radiance  Color   // radiance arriving along Direction when unoccluded
+	Pdf       float64 // solid angle density of Direction
+	Delta     bool    // light from a single point or direction, which scattered rays never find
+}
+
+// Light is a source of illumination that the integrator can sample directly
+// instead of waiting for a scattered ray to stumble onto it. Pdf_li is the
+// solid angle density of Sample_li choosing direction from p, and is zero for
+// delta lights.
+type Light interface {
+	Sample_li(p Point3) (Light_sample, bool)
+	Pdf_li(p Point3, direction Vec3) float64
+}
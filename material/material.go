@@ -4,7 +4,106 @@ import (
 	. "raytracer/common"
 )
 
+// Bsdf_flags describe the kinds of scattering lobes a material has
+type Bsdf_flags int
+
+const (
+	Bsdf_diffuse Bsdf_flags = 1 << iota
+	Bsdf_glossy
+	Bsdf_specular // delta lobe, cannot be evaluated or light sampled
+	Bsdf_reflection
+	Bsdf_transmission
+	Bsdf_volume     // phase function of a medium, evaluated without a cosine term
+	Bsdf_dispersive // sampled for the ray's Wavelength, which no other wavelength can follow
+
+	Bsdf_none Bsdf_flags = 0
+)
+
+// Is_delta reports whether the flags only describe delta lobes
+func (f Bsdf_flags) Is_delta() bool {
+	return f&(Bsdf_diffuse|Bsdf_glossy) == 0
+}
+
+// Partly_delta is implemented by materials whose Flags are not delta but
+// whose Sample can still pick a delta lobe, like a Mix of a mirror and a
+// diffuse material. Delta_chance is the chance it does so at a hit.
+type Partly_delta interface {
+	Delta_chance(r_in *Ray, rec *Hit_record) float64
+}
+
+// Delta_chance is the chance that m samples a delta lobe at a hit
+func Delta_chance(m Material, r_in *Ray, rec *Hit_record) float64 {
+	if m.Flags().Is_delta() {
+		return 1
+	}
+	if partly, ok := m.(Partly_delta); ok {
+		return partly.Delta_chance(r_in, rec)
+	}
+	return 0
+}
+
+// Bsdf_sample is a scattered direction chosen by Material.Sample
+type Bsdf_sample struct {
+	Direction Vec3       // scattered direction
+	Weight    Color      // BSDF * cosine / pdf, the path throughput multiplier
+	Pdf       float64    // solid angle density of Direction, zero for delta lobes
+	Flags     Bsdf_flags // lobe that produced the sample
+}
+
+// Material describes how a surface scatters and emits light.
+//
+// Sample picks a scattered direction for r_in. Eval returns the BSDF times
+// the cosine term for scattering r_in into direction, and Pdf the solid angle
+// density with which Sample would pick direction. Delta lobes evaluate to
+// zero. Flags lists every lobe the material may sample.
 type Material interface {
+	Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool)
+	Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color
+	Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64
+	Flags() Bsdf_flags
+	Emitted(u float64, v float64, p *Point3) Color
+}
+
+// Scatterer is the original material interface, which can only generate
+// random scattered rays
+type Scatterer interface {
 	Scatter(r *Ray, rec *Hit_record, attenuation *Color, scattered *Ray) bool
 	Emitted(u float64, v float64, p *Point3) Color
 }
+
+// Scatter_material adapts a Scatterer to the Material interface. Since its
+// distribution is unknown it is treated as a delta lobe and never light sampled.
+type Scatter_material struct {
+	scatterer Scatterer
+}
+
+func NewScatter_material(s Scatterer) Scatter_material {
+	return Scatter_material{scatterer: s}
+}
+
+func (m *Scatter_material) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+	var attenuation Color
+	var scattered Ray
+
+	if !m.scatterer.Scatter(r_in, rec, &attenuation, &scattered) {
+		return Bsdf_sample{}, false
+	}
+
+	return Bsdf_sample{Direction: scattered.Direction, Weight: attenuation, Flags: Bsdf_specular}, true
+}
+
+func (m *Scatter_material) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	return NewColor(0, 0, 0)
+}
+
+func (m *Scatter_material) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	return 0
+}
+
+func (m *Scatter_material) Flags() Bsdf_flags {
+	return Bsdf_specular
+}
+
+func (m *Scatter_material) Emitted(u float64, v float64, p *Point3) Color {
+	return m.scatterer.Emitted(u, v, p)
+}
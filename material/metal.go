@@ -1,6 +1,7 @@
 package material
 
 import (
+	"math"
 	. "raytracer/common"
 )
 
@@ -13,12 +14,67 @@ func NewMetal(c Color, f float64) Metal {
 	return Metal{Albedo: c, Fuzz: f}
 }
 
-func (m *Metal) Scatter(r *Ray, rec *Hit_record, attenuation *Color, scattered *Ray) bool {
-	reflected := Reflect(Unit_vector(r.Direction), rec.Normal)
-	*scattered = NewRay(rec.P, reflected.Add(Random_unit_vector().Mult(m.Fuzz)))
-	*attenuation = m.Albedo
-	return true
+func (m *Metal) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+	reflected := Reflect(Unit_vector(r_in.Direction), rec.Normal)
+	direction := reflected.Add(Random_unit_vector().Mult(m.Fuzz))
 
+	// Fuzzed reflections pointing into the surface are absorbed
+	if Dot(direction, rec.Normal) <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	return Bsdf_sample{
+		Direction: direction,
+		Weight:    m.Albedo,
+		Pdf:       m.Pdf(r_in, rec, direction),
+		Flags:     m.Flags(),
+	}, true
+
+}
+
+// Eval follows from Sample returning Albedo as its weight: f*cos/pdf = Albedo
+func (m *Metal) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	return m.Albedo.Mult(m.Pdf(r_in, rec, direction))
+}
+
+// Pdf of Sample, which perturbs the mirror direction R by a point uniformly
+// distributed on a sphere of radius Fuzz. A direction d is produced by the
+// points where it pierces that sphere at distances t, giving a density of
+// sum(t^2) / (4*pi*Fuzz*sqrt(disc)). A perfect mirror has no density.
+func (m *Metal) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	if m.Fuzz <= 0 {
+		return 0
+	}
+
+	d := Unit_vector(direction)
+	if Dot(d, rec.Normal) <= 0 {
+		return 0
+	}
+
+	reflected := Reflect(Unit_vector(r_in.Direction), rec.Normal)
+	d_dot_r := Dot(d, reflected)
+
+	disc := d_dot_r*d_dot_r - (1 - m.Fuzz*m.Fuzz)
+	if disc <= 0 {
+		return 0
+	}
+	sqrtd := math.Sqrt(disc)
+
+	sum := 0.0
+	for _, t := range []float64{d_dot_r - sqrtd, d_dot_r + sqrtd} {
+		if t > 0 {
+			sum += t * t
+		}
+	}
+
+	return sum / (4 * Pi * m.Fuzz * sqrtd)
+}
+
+func (m *Metal) Flags() Bsdf_flags {
+	if m.Fuzz <= 0 {
+		return Bsdf_specular | Bsdf_reflection
+	}
+	return Bsdf_glossy | Bsdf_reflection
 }
 
 func (m *Metal) Emitted(u float64, v float64, p *Point3) Color {
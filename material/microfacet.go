@@ -0,0 +1,236 @@
+package material
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+)
+
+// Microfacet models treat a rough surface as tiny mirror facets whose normals
+// follow a distribution around the shading normal. Everything here works in
+// a local frame where the shading normal is +z.
+
+// ggx is the Trowbridge-Reitz distribution with roughness alpha_x and
+// alpha_y along the x and y tangents
+type ggx struct {
+	alpha_x, alpha_y float64
+}
+
+// new_ggx maps a perceptual roughness in [0, 1] to alpha = roughness^2,
+// clamped away from zero where the distribution becomes a delta
+func new_ggx(roughness_x float64, roughness_y float64) ggx {
+	return ggx{alpha_x: math.Max(1e-3, roughness_x*roughness_x), alpha_y: math.Max(1e-3, roughness_y*roughness_y)}
+}
+
+// d is the density of microfacet normal m per unit projected area
+func (g ggx) d(m Vec3) float64 {
+	if m.Z() <= 0 {
+		return 0
+	}
+	x := m.X() / g.alpha_x
+	y := m.Y() / g.alpha_y
+	e := x*x + y*y + m.Z()*m.Z()
+	return 1 / (Pi * g.alpha_x * g.alpha_y * e * e)
+}
+
+// lambda is Smith's auxiliary function, the shadowed microfacet area seen
+// from w relative to the visible area
+func (g ggx) lambda(w Vec3) float64 {
+	if w.Z() == 0 {
+		return math.Inf(1)
+	}
+	x := g.alpha_x * w.X()
+	y := g.alpha_y * w.Y()
+	tan2 := (x*x + y*y) / (w.Z() * w.Z())
+	return (math.Sqrt(1+tan2) - 1) / 2
+}
+
+// g1 is the fraction of microfacets visible from w
+func (g ggx) g1(w Vec3) float64 {
+	return 1 / (1 + g.lambda(w))
+}
+
+// g is the fraction of microfacets visible from both wo and wi
+func (g ggx) g(wo Vec3, wi Vec3) float64 {
+	return 1 / (1 + g.lambda(wo) + g.lambda(wi))
+}
+
+// sample_visible picks a microfacet normal in proportion to how much of it
+// wo sees, following Heitz (2018). wo must be above the surface.
+func (g ggx) sample_visible(wo Vec3) Vec3 {
+
+	// Stretch to a hemisphere configuration
+	vh := Unit_vector(NewVec3(g.alpha_x*wo.X(), g.alpha_y*wo.Y(), wo.Z()))
+
+	t1 := NewVec3(1, 0, 0)
+	if length_sq := vh.X()*vh.X() + vh.Y()*vh.Y(); length_sq > 0 {
+		t1 = NewVec3(-vh.Y(), vh.X(), 0).Div(math.Sqrt(length_sq))
+	}
+	t2 := Cross(vh, t1)
+
+	// Uniform disk point, squashed onto the part of the projected
+	// hemisphere that is visible
+	r := math.Sqrt(rand.Float64())
+	phi := 2 * Pi * rand.Float64()
+	p1 := r * math.Cos(phi)
+	p2 := r * math.Sin(phi)
+	s := 0.5 * (1 + vh.Z())
+	p2 = (1-s)*math.Sqrt(1-p1*p1) + s*p2
+
+	nh := t1.Mult(p1).Add(t2.Mult(p2)).Add(vh.Mult(math.Sqrt(math.Max(0, 1-p1*p1-p2*p2))))
+
+	// Unstretch
+	return Unit_vector(NewVec3(g.alpha_x*nh.X(), g.alpha_y*nh.Y(), math.Max(1e-6, nh.Z())))
+}
+
+// pdf_visible is the density of sample_visible returning m
+func (g ggx) pdf_visible(wo Vec3, m Vec3) float64 {
+	if wo.Z() <= 0 {
+		return 0
+	}
+	return g.g1(wo) * math.Max(0, Dot(wo, m)) * g.d(m) / wo.Z()
+}
+
+// fresnel_dielectric is the unpolarized reflectance of light arriving at
+// cos_i to a boundary where eta is the ratio of the far IOR to the near one
+func fresnel_dielectric(cos_i float64, eta float64) float64 {
+
+	cos_i = math.Max(-1, math.Min(1, cos_i))
+	if cos_i < 0 {
+		eta = 1 / eta
+		cos_i = -cos_i
+	}
+
+	sin2_t := (1 - cos_i*cos_i) / (eta * eta)
+	if sin2_t >= 1 {
+		return 1
+	}
+	cos_t := math.Sqrt(1 - sin2_t)
+
+	r_parallel := (eta*cos_i - cos_t) / (eta*cos_i + cos_t)
+	r_perpendicular := (cos_i - eta*cos_t) / (cos_i + eta*cos_t)
+
+	return (r_parallel*r_parallel + r_perpendicular*r_perpendicular) / 2
+}
+
+// schlick approximates the reflectance rising from f0 at normal incidence
+// to one at grazing
+func schlick(f0 Color, cosine float64) Color {
+	m := math.Pow(math.Max(0, 1-cosine), 5)
+	return f0.Add(NewColor(1, 1, 1).Sub(f0).Mult(m))
+}
+
+// reflect_local mirrors wo about the microfacet normal m
+func reflect_local(wo Vec3, m Vec3) Vec3 {
+	return wo.Mult(-1).Add(m.Mult(2 * Dot(wo, m)))
+}
+
+// refract_local bends wo through the microfacet normal m into a medium eta
+// times denser, reporting false on total internal reflection
+func refract_local(wo Vec3, m Vec3, eta float64) (Vec3, bool) {
+
+	cos_i := Dot(wo, m)
+	sin2_t := math.Max(0, 1-cos_i*cos_i) / (eta * eta)
+	if sin2_t >= 1 {
+		return Vec3{}, false
+	}
+	cos_t := math.Sqrt(1 - sin2_t)
+
+	return wo.Mult(-1 / eta).Add(m.Mult(cos_i/eta - cos_t)), true
+}
+
+// microfacet_dielectric is the Walter et al. (2007) microfacet BSDF of a rough
+// boundary between two dielectrics, where eta is the ratio of the IOR below
+// the surface to the one above. Like Dielectric it leaves out the 1/eta^2
+// change in radiance, which cancels for light that leaves the object again.
+type microfacet_dielectric struct {
+	distribution ggx
+	eta          float64
+}
+
+// eval returns the BSDF times |cos| of wi, both directions in the local frame
+// with wo above the surface
+func (b microfacet_dielectric) eval(wo Vec3, wi Vec3) (reflected float64, transmitted float64) {
+
+	if wo.Z() <= 0 || wi.Z() == 0 {
+		return 0, 0
+	}
+
+	if wi.Z() > 0 {
+		m := Unit_vector(wo.Add(wi))
+		f := fresnel_dielectric(Dot(wo, m), b.eta)
+		return b.distribution.d(m) * b.distribution.g(wo, wi) * f / (4 * wo.Z()), 0
+	}
+
+	m, denominator, ok := b.transmission_normal(wo, wi)
+	if !ok {
+		return 0, 0
+	}
+
+	f := fresnel_dielectric(Dot(wo, m), b.eta)
+	ft := b.distribution.d(m) * b.distribution.g(wo, wi) * (1 - f) *
+		math.Abs(Dot(wi, m)*Dot(wo, m)/(wo.Z()*denominator))
+
+	return 0, ft
+}
+
+// pdf is the density of sample returning wi
+func (b microfacet_dielectric) pdf(wo Vec3, wi Vec3) float64 {
+
+	if wo.Z() <= 0 || wi.Z() == 0 {
+		return 0
+	}
+
+	if wi.Z() > 0 {
+		m := Unit_vector(wo.Add(wi))
+		f := fresnel_dielectric(Dot(wo, m), b.eta)
+		return f * b.distribution.pdf_visible(wo, m) / (4 * math.Abs(Dot(wo, m)))
+	}
+
+	m, denominator, ok := b.transmission_normal(wo, wi)
+	if !ok {
+		return 0
+	}
+
+	f := fresnel_dielectric(Dot(wo, m), b.eta)
+	return (1 - f) * b.distribution.pdf_visible(wo, m) * math.Abs(Dot(wi, m)) / denominator
+}
+
+// transmission_normal finds the microfacet normal that refracts wo into wi,
+// and the squared denominator of the change of variables between them
+func (b microfacet_dielectric) transmission_normal(wo Vec3, wi Vec3) (Vec3, float64, bool) {
+
+	m := Unit_vector(wi.Mult(b.eta).Add(wo))
+	if m.Z() < 0 {
+		m = m.Mult(-1)
+	}
+
+	// The facet must face wo and turn its back on wi
+	if Dot(m, wo) <= 0 || Dot(m, wi) >= 0 {
+		return Vec3{}, 0, false
+	}
+
+	denominator := Dot(wi, m) + Dot(wo, m)/b.eta
+	return m, denominator * denominator, true
+}
+
+// sample picks wi by choosing a visible microfacet and then reflecting or
+// refracting in proportion to its Fresnel reflectance
+func (b microfacet_dielectric) sample(wo Vec3) (Vec3, bool) {
+
+	if wo.Z() <= 0 {
+		return Vec3{}, false
+	}
+
+	m := b.distribution.sample_visible(wo)
+	f := fresnel_dielectric(Dot(wo, m), b.eta)
+
+	if rand.Float64() < f {
+		wi := reflect_local(wo, m)
+		return wi, wi.Z() > 0
+	}
+
+	wi, ok := refract_local(wo, m, b.eta)
+	return wi, ok && wi.Z() < 0
+}
@@ -0,0 +1,178 @@
+package material
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+)
+
+// Mix blends two materials, weighting B by Factor and A by what is left. A
+// textured factor paints one material over the other, like rust patches on
+// metal or a checker of glass and diffuse, and mixing in a Diffuse_light
+// paints emissive decals onto a surface. Emission is blended the same way.
+//
+// A Fresnel mix instead weights B by the reflectance of a dielectric with
+// the given IOR, so B takes over at grazing angles as the gloss of a
+// polished surface does.
+//
+// Sample picks one of the two materials in proportion to its weight, while
+// Eval and Pdf blend both. A filled component such as tinted or nested glass
+// keeps its medium, IOR and priority, see Interior.
+type Mix struct {
+	A, B        Material
+	Factor      Texture // weight of B, averaged over the channels
+	fresnel_ior float64
+}
+
+func NewMix(a Material, b Material, factor float64) Mix {
+	return Mix{A: a, B: b, Factor: constant_texture(factor)}
+}
+
+func NewTextured_mix(a Material, b Material, mask Texture) Mix {
+	return Mix{A: a, B: b, Factor: mask}
+}
+
+func NewFresnel_mix(a Material, b Material, ior float64) Mix {
+	return Mix{A: a, B: b, fresnel_ior: ior}
+}
+
+// weight is the weight of B at a hit seen along r_in
+func (m *Mix) weight(r_in *Ray, rec *Hit_record) float64 {
+	if m.fresnel_ior > 0 {
+		cos_i := -Dot(Unit_vector(r_in.Direction), rec.Normal)
+		return fresnel_dielectric(math.Abs(cos_i), m.fresnel_ior)
+	}
+	return m.factor(rec.U, rec.V, &rec.P)
+}
+
+func (m *Mix) factor(u float64, v float64, p *Point3) float64 {
+	if m.fresnel_ior > 0 {
+		// No direction is known, so take the reflectance head on
+		r0 := (m.fresnel_ior - 1) / (m.fresnel_ior + 1)
+		return r0 * r0
+	}
+	c := m.Factor.Value(u, v, p)
+	return math.Max(0, math.Min(1, (c.X()+c.Y()+c.Z())/3))
+}
+
+func (m *Mix) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	t := m.weight(r_in, rec)
+
+	chosen := m.A
+	if rand.Float64() < t {
+		chosen = m.B
+	}
+
+	// The chance of picking a material cancels its weight in the blend
+	bs, ok := chosen.Sample(r_in, rec)
+	if !ok || bs.Flags&Bsdf_specular != 0 || t == 0 || t == 1 {
+		return bs, ok
+	}
+
+	bs.Pdf = (1-t)*m.A.Pdf(r_in, rec, bs.Direction) + t*m.B.Pdf(r_in, rec, bs.Direction)
+	return bs, true
+}
+
+func (m *Mix) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+
+	t := m.weight(r_in, rec)
+
+	switch t {
+	case 0:
+		return m.A.Eval(r_in, rec, direction)
+	case 1:
+		return m.B.Eval(r_in, rec, direction)
+	}
+
+	return m.A.Eval(r_in, rec, direction).Mult(1 - t).Add(m.B.Eval(r_in, rec, direction).Mult(t))
+}
+
+func (m *Mix) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+
+	t := m.weight(r_in, rec)
+
+	switch t {
+	case 0:
+		return m.A.Pdf(r_in, rec, direction)
+	case 1:
+		return m.B.Pdf(r_in, rec, direction)
+	}
+
+	return (1-t)*m.A.Pdf(r_in, rec, direction) + t*m.B.Pdf(r_in, rec, direction)
+}
+
+func (m *Mix) Flags() Bsdf_flags {
+	return m.A.Flags() | m.B.Flags()
+}
+
+// Delta_chance is the chance Sample picks a delta lobe, from whichever
+// material it picks
+func (m *Mix) Delta_chance(r_in *Ray, rec *Hit_record) float64 {
+	t := m.weight(r_in, rec)
+	return (1-t)*Delta_chance(m.A, r_in, rec) + t*Delta_chance(m.B, r_in, rec)
+}
+
+func (m *Mix) Emitted(u float64, v float64, p *Point3) Color {
+
+	t := m.factor(u, v, p)
+
+	switch t {
+	case 0:
+		return m.A.Emitted(u, v, p)
+	case 1:
+		return m.B.Emitted(u, v, p)
+	}
+
+	return m.A.Emitted(u, v, p).Mult(1 - t).Add(m.B.Emitted(u, v, p).Mult(t))
+}
+
+// Interior is the medium of whichever component is filled, blended by the
+// factor if both are, or clear air if neither is
+func (m *Mix) Interior(rec *Hit_record) Medium {
+
+	a, a_filled := m.A.(Interior)
+	b, b_filled := m.B.(Interior)
+
+	switch {
+	case a_filled && b_filled:
+		t := m.factor(rec.U, rec.V, &rec.P)
+		ma, mb := a.Interior(rec), b.Interior(rec)
+		return Medium{
+			Sigma_t: ma.Sigma_t.Mult(1 - t).Add(mb.Sigma_t.Mult(t)),
+			Albedo:  ma.Albedo.Mult(1 - t).Add(mb.Albedo.Mult(t)),
+		}
+	case a_filled:
+		return a.Interior(rec)
+	case b_filled:
+		return b.Interior(rec)
+	}
+
+	return Medium{}
+}
+
+// nested is the component that is Nested, B before A
+func (m *Mix) nested() (Nested, bool) {
+	if nested, ok := m.B.(Nested); ok {
+		return nested, true
+	}
+	nested, ok := m.A.(Nested)
+	return nested, ok
+}
+
+func (m *Mix) Index_of_refraction() float64 {
+	if nested, ok := m.nested(); ok {
+		return nested.Index_of_refraction()
+	}
+	return 1
+}
+
+// Medium_priority is the nested component's, or -1 when neither component
+// is Nested so the mix acts as a plain filled object
+func (m *Mix) Medium_priority() int {
+	if nested, ok := m.nested(); ok {
+		return nested.Medium_priority()
+	}
+	return -1
+}
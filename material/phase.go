@@ -0,0 +1,122 @@
+package material
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+)
+
+// Phase functions are the materials of participating media: they describe
+// how light is scattered at a point inside a volume rather than on a surface.
+// Eval returns the phase function times the albedo, with no cosine term.
+
+// Isotropic scatters equally in every direction
+type Isotropic struct {
+	tex Texture
+}
+
+func NewIsotropic(albedo Color) Isotropic {
+	tex := NewSolid_color(&albedo)
+	return Isotropic{tex: &tex}
+}
+
+func NewTexturedIsotropic(tex Texture) Isotropic {
+	return Isotropic{tex: tex}
+}
+
+func (i *Isotropic) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+	return Bsdf_sample{
+		Direction: Random_unit_vector(),
+		Weight:    i.tex.Value(rec.U, rec.V, &rec.P),
+		Pdf:       1 / (4 * Pi),
+		Flags:     i.Flags(),
+	}, true
+}
+
+func (i *Isotropic) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	return i.tex.Value(rec.U, rec.V, &rec.P).Mult(1 / (4 * Pi))
+}
+
+func (i *Isotropic) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	return 1 / (4 * Pi)
+}
+
+func (i *Isotropic) Flags() Bsdf_flags {
+	return Bsdf_diffuse | Bsdf_volume
+}
+
+func (i *Isotropic) Emitted(u float64, v float64, p *Point3) Color {
+	return NewColor(0, 0, 0)
+}
+
+// Henyey_greenstein favours scattering forwards (G > 0) or backwards (G < 0),
+// as haze and smoke particles do. G is the mean cosine of the scattering
+// angle, between -1 and 1; zero is isotropic.
+type Henyey_greenstein struct {
+	tex Texture
+	G   float64
+}
+
+func NewHenyey_greenstein(albedo Color, g float64) Henyey_greenstein {
+	tex := NewSolid_color(&albedo)
+	return Henyey_greenstein{tex: &tex, G: g}
+}
+
+// Sample inverts the distribution of the cosine between the incoming and
+// scattered directions, then picks the azimuth uniformly
+func (h *Henyey_greenstein) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	g := h.G
+	xi := rand.Float64()
+
+	var cos_theta float64
+	if math.Abs(g) < 1e-3 {
+		cos_theta = 1 - 2*xi
+	} else {
+		s := (1 - g*g) / (1 - g + 2*g*xi)
+		cos_theta = (1 + g*g - s*s) / (2 * g)
+	}
+	cos_theta = math.Max(-1, math.Min(1, cos_theta))
+
+	sin_theta := math.Sqrt(math.Max(0, 1-cos_theta*cos_theta))
+	phi := 2 * Pi * rand.Float64()
+
+	uvw := NewOnb(Unit_vector(r_in.Direction))
+	direction := uvw.Local(NewVec3(sin_theta*math.Cos(phi), sin_theta*math.Sin(phi), cos_theta))
+
+	// Sampling matches the phase function exactly, leaving only the albedo
+	return Bsdf_sample{
+		Direction: direction,
+		Weight:    h.tex.Value(rec.U, rec.V, &rec.P),
+		Pdf:       henyey_greenstein(cos_theta, g),
+		Flags:     h.Flags(),
+	}, true
+}
+
+func (h *Henyey_greenstein) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	return h.tex.Value(rec.U, rec.V, &rec.P).Mult(h.Pdf(r_in, rec, direction))
+}
+
+func (h *Henyey_greenstein) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	cos_theta := Dot(Unit_vector(r_in.Direction), Unit_vector(direction))
+	return henyey_greenstein(cos_theta, h.G)
+}
+
+func (h *Henyey_greenstein) Flags() Bsdf_flags {
+	if h.G == 0 {
+		return Bsdf_diffuse | Bsdf_volume
+	}
+	return Bsdf_glossy | Bsdf_volume
+}
+
+func (h *Henyey_greenstein) Emitted(u float64, v float64, p *Point3) Color {
+	return NewColor(0, 0, 0)
+}
+
+// henyey_greenstein is the phase function for the cosine between the
+// direction of travel and the scattered direction
+func henyey_greenstein(cos_theta float64, g float64) float64 {
+	denom := 1 + g*g - 2*g*cos_theta
+	return (1 - g*g) / (4 * Pi * denom * math.Sqrt(denom))
+}
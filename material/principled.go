@@ -0,0 +1,314 @@
+package material
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+)
+
+// Principled is a Disney style uber material whose parameters blend between
+// the usual kinds of surface: a diffuse base with sheen, a dielectric or
+// metallic GGX specular lobe, a rough glass lobe and a clearcoat on top.
+// Every parameter is a texture; the scalar ones read the average of the
+// texture's channels, clamped to [0, 1] except for Ior.
+//
+// The lobes are layered so energy is conserved: the clearcoat takes its
+// Fresnel share before the rest, and the diffuse base only gets what the
+// specular lobe does not reflect. Sample picks a lobe in proportion to its
+// estimated reflectance, and Pdf and Eval sum over all of them.
+type Principled struct {
+	Base_color      Texture
+	Metallic        Texture
+	Roughness       Texture
+	Specular        Texture // dielectric reflectance, 0.5 is 4%
+	Specular_tint   Texture // tints dielectric reflections towards the base color
+	Sheen           Texture // extra grazing reflection for cloth
+	Clearcoat       Texture
+	Clearcoat_gloss Texture
+	Transmission    Texture // share of the dielectric base that is glass
+	Ior             Texture // for transmission
+}
+
+func NewPrincipled(base_color Color, metallic float64, roughness float64) Principled {
+	base := NewSolid_color(&base_color)
+	return Principled{
+		Base_color:      &base,
+		Metallic:        constant_texture(metallic),
+		Roughness:       constant_texture(roughness),
+		Specular:        constant_texture(0.5),
+		Specular_tint:   constant_texture(0),
+		Sheen:           constant_texture(0),
+		Clearcoat:       constant_texture(0),
+		Clearcoat_gloss: constant_texture(1),
+		Transmission:    constant_texture(0),
+		Ior:             constant_texture(1.5),
+	}
+}
+
+func constant_texture(value float64) Texture {
+	tex := NewSolid_colorRGB(value, value, value)
+	return &tex
+}
+
+// principled_lobes holds the parameters looked up at one hit
+type principled_lobes struct {
+	frame          Onb
+	base           Color
+	spec0          Color // specular reflectance at normal incidence
+	sheen          Color
+	roughness      float64
+	metallic       float64
+	transmission   float64
+	clearcoat      float64
+	specular       ggx
+	coat           float64 // clearcoat GTR1 alpha
+	glass          microfacet_dielectric
+	diffuse_weight float64 // scales the base for what the specular lobe and coat take
+	coat_weight    float64 // scales everything under the coat
+	pick           [4]float64
+}
+
+const (
+	lobe_diffuse = iota
+	lobe_specular
+	lobe_clearcoat
+	lobe_glass
+)
+
+func (p *Principled) lobes(r_in *Ray, rec *Hit_record) (principled_lobes, Vec3) {
+
+	scalar := func(tex Texture) float64 {
+		c := tex.Value(rec.U, rec.V, &rec.P)
+		return math.Max(0, math.Min(1, (c.X()+c.Y()+c.Z())/3))
+	}
+
+	var l principled_lobes
+
+	l.frame = NewOnb(rec.Normal)
+	wo := l.frame.To_local(Unit_vector(r_in.Direction).Mult(-1))
+
+	l.base = p.Base_color.Value(rec.U, rec.V, &rec.P)
+	l.metallic = scalar(p.Metallic)
+	l.roughness = scalar(p.Roughness)
+	l.transmission = scalar(p.Transmission)
+	l.clearcoat = scalar(p.Clearcoat)
+
+	tint := NewColor(1, 1, 1)
+	if lum := luminance(l.base); lum > 0 {
+		tint = l.base.Div(lum)
+	}
+
+	dielectric := lerp_color(NewColor(1, 1, 1), tint, scalar(p.Specular_tint)).Mult(0.08 * scalar(p.Specular))
+	l.spec0 = lerp_color(dielectric, l.base, l.metallic)
+	l.sheen = lerp_color(NewColor(1, 1, 1), tint, 0.5).Mult(scalar(p.Sheen))
+
+	l.specular = new_ggx(l.roughness, l.roughness)
+	l.coat = lerp(0.1, 0.001, scalar(p.Clearcoat_gloss))
+
+	ior := p.Ior.Value(rec.U, rec.V, &rec.P)
+	eta := (ior.X() + ior.Y() + ior.Z()) / 3
+	if eta <= 0 {
+		eta = 1
+	}
+	if !rec.Front_face {
+		eta = 1 / eta
+	}
+	l.glass = microfacet_dielectric{distribution: l.specular, eta: eta}
+
+	cos_o := math.Max(0, wo.Z())
+	coat_fresnel := 0.25 * l.clearcoat * schlick(NewColor(0.04, 0.04, 0.04), cos_o).X()
+	l.coat_weight = 1 - coat_fresnel
+	l.diffuse_weight = (1 - l.metallic) * (1 - l.transmission) * (1 - luminance(schlick(dielectric, cos_o)))
+
+	// Chances of sampling each lobe, from its rough share of the reflectance
+	l.pick[lobe_diffuse] = l.coat_weight * l.diffuse_weight * math.Max(luminance(l.base), luminance(l.sheen))
+	l.pick[lobe_specular] = l.coat_weight * (1 - (1-l.metallic)*l.transmission) * luminance(schlick(l.spec0, cos_o))
+	l.pick[lobe_clearcoat] = coat_fresnel
+	l.pick[lobe_glass] = l.coat_weight * (1 - l.metallic) * l.transmission
+
+	total := l.pick[0] + l.pick[1] + l.pick[2] + l.pick[3]
+	if total <= 0 {
+		l.pick = [4]float64{1, 0, 0, 0}
+	} else {
+		for i := range l.pick {
+			l.pick[i] /= total
+		}
+	}
+
+	return l, wo
+}
+
+// eval returns the BSDF times |cos| for local directions wo and wi
+func (l *principled_lobes) eval(wo Vec3, wi Vec3) Color {
+
+	result := NewColor(0, 0, 0)
+	if wo.Z() <= 0 {
+		return result
+	}
+
+	if l.transmission > 0 && l.metallic < 1 {
+		reflected, transmitted := l.glass.eval(wo, wi)
+		glass := NewColor(reflected, reflected, reflected).Add(l.base.Mult(transmitted))
+		result = result.Add(glass.Mult(l.coat_weight * (1 - l.metallic) * l.transmission))
+	}
+
+	if wi.Z() <= 0 {
+		return result
+	}
+
+	h := Unit_vector(wo.Add(wi))
+	cos_d := Dot(wi, h)
+
+	// Burley's diffuse with retro reflection at grazing angles, and sheen
+	fl := math.Pow(1-wi.Z(), 5)
+	fv := math.Pow(1-wo.Z(), 5)
+	rr := 2 * l.roughness * cos_d * cos_d
+	diffuse := (1-0.5*fl)*(1-0.5*fv) + rr*(fl+fv+fl*fv*(rr-1))
+	base := l.base.Mult(diffuse / Pi).Add(l.sheen.Mult(math.Pow(1-cos_d, 5)))
+	result = result.Add(base.Mult(l.coat_weight * l.diffuse_weight * wi.Z()))
+
+	// GGX specular lobe, everywhere but the glass
+	specular := schlick(l.spec0, cos_d).Mult(l.specular.d(h) * l.specular.g(wo, wi) / (4 * wo.Z()))
+	result = result.Add(specular.Mult(l.coat_weight * (1 - (1-l.metallic)*l.transmission)))
+
+	// Clearcoat, with a fixed roughness of 0.25 for its shadowing
+	if l.clearcoat > 0 {
+		coat_g := new_ggx(0.5, 0.5)
+		f := schlick(NewColor(0.04, 0.04, 0.04), cos_d).X()
+		coat := 0.25 * l.clearcoat * gtr1(h.Z(), l.coat) * coat_g.g(wo, wi) * f / (4 * wo.Z())
+		result = result.Add(NewColor(coat, coat, coat))
+	}
+
+	return result
+}
+
+func (l *principled_lobes) pdf(wo Vec3, wi Vec3) float64 {
+
+	if wo.Z() <= 0 {
+		return 0
+	}
+
+	pdf := 0.0
+
+	if l.pick[lobe_glass] > 0 {
+		pdf += l.pick[lobe_glass] * l.glass.pdf(wo, wi)
+	}
+
+	if wi.Z() <= 0 {
+		return pdf
+	}
+
+	h := Unit_vector(wo.Add(wi))
+
+	pdf += l.pick[lobe_diffuse] * wi.Z() / Pi
+	pdf += l.pick[lobe_specular] * l.specular.pdf_visible(wo, h) / (4 * Dot(wo, h))
+	pdf += l.pick[lobe_clearcoat] * gtr1(h.Z(), l.coat) * h.Z() / (4 * Dot(wo, h))
+
+	return pdf
+}
+
+func (p *Principled) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	l, wo := p.lobes(r_in, rec)
+	if wo.Z() <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	u := rand.Float64()
+	lobe := 0
+	for lobe < lobe_glass && u >= l.pick[lobe] {
+		u -= l.pick[lobe]
+		lobe++
+	}
+
+	var wi Vec3
+
+	switch lobe {
+	case lobe_diffuse:
+		wi = Random_cosine_direction()
+	case lobe_specular:
+		wi = reflect_local(wo, l.specular.sample_visible(wo))
+	case lobe_clearcoat:
+		wi = reflect_local(wo, sample_gtr1(l.coat))
+	case lobe_glass:
+		var ok bool
+		if wi, ok = l.glass.sample(wo); !ok {
+			return Bsdf_sample{}, false
+		}
+	}
+
+	// Only the glass transmits; a reflection lobe turned below the surface
+	// has no density in pdf for the lobe that made it
+	if lobe != lobe_glass && wi.Z() <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	pdf := l.pdf(wo, wi)
+	if pdf <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	flags := Bsdf_glossy | Bsdf_reflection
+	if lobe == lobe_diffuse {
+		flags = Bsdf_diffuse | Bsdf_reflection
+	} else if wi.Z() < 0 {
+		flags = Bsdf_glossy | Bsdf_transmission
+	}
+
+	return Bsdf_sample{
+		Direction: l.frame.Local(wi),
+		Weight:    l.eval(wo, wi).Div(pdf),
+		Pdf:       pdf,
+		Flags:     flags,
+	}, true
+}
+
+func (p *Principled) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	l, wo := p.lobes(r_in, rec)
+	return l.eval(wo, l.frame.To_local(Unit_vector(direction)))
+}
+
+func (p *Principled) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	l, wo := p.lobes(r_in, rec)
+	return l.pdf(wo, l.frame.To_local(Unit_vector(direction)))
+}
+
+func (p *Principled) Flags() Bsdf_flags {
+	return Bsdf_diffuse | Bsdf_glossy | Bsdf_reflection | Bsdf_transmission
+}
+
+func (p *Principled) Emitted(u float64, v float64, point *Point3) Color {
+	return NewColor(0, 0, 0)
+}
+
+// gtr1 is the Berry distribution used for the clearcoat, with a longer tail
+// than GGX
+func gtr1(cos_h float64, alpha float64) float64 {
+	if cos_h <= 0 {
+		return 0
+	}
+	a2 := alpha * alpha
+	return (a2 - 1) / (Pi * math.Log(a2) * (1 + (a2-1)*cos_h*cos_h))
+}
+
+// sample_gtr1 picks a normal with density gtr1 * cos
+func sample_gtr1(alpha float64) Vec3 {
+	a2 := alpha * alpha
+	cos_h := math.Sqrt(math.Max(0, (1-math.Pow(a2, 1-rand.Float64()))/(1-a2)))
+	sin_h := math.Sqrt(math.Max(0, 1-cos_h*cos_h))
+	phi := 2 * Pi * rand.Float64()
+	return NewVec3(sin_h*math.Cos(phi), sin_h*math.Sin(phi), cos_h)
+}
+
+func luminance(c Color) float64 {
+	return 0.2126*c.X() + 0.7152*c.Y() + 0.0722*c.Z()
+}
+
+func lerp(a float64, b float64, t float64) float64 {
+	return a + (b-a)*t
+}
+
+func lerp_color(a Color, b Color, t float64) Color {
+	return a.Mult(1 - t).Add(b.Mult(t))
+}
@@ -0,0 +1,119 @@
+package material
+
+import (
+	. "raytracer/common"
+)
+
+// Rough_dielectric is glass with a GGX microfacet surface, after Walter et
+// al. (2007), which blurs both reflections and refractions: frosted glass,
+// ice or satin plastics. Each sample picks a visible microfacet and then
+// reflects or refracts through it by its Fresnel reflectance. A roughness of
+// zero behaves like Dielectric, and Absorption and Priority work as they
+// do there.
+type Rough_dielectric struct {
+	Ior          float64
+	Absorption   Color
+	Priority     int
+	roughness    float64
+	distribution ggx
+}
+
+func NewRough_dielectric(ior float64, roughness float64) Rough_dielectric {
+	return Rough_dielectric{Ior: ior, roughness: roughness, distribution: new_ggx(roughness, roughness)}
+}
+
+// bsdf is the microfacet BSDF for the side of the surface the ray is on
+func (d *Rough_dielectric) bsdf(r_in *Ray, rec *Hit_record) (microfacet_dielectric, Onb, Vec3) {
+
+	eta := d.Ior / outside_ior(rec)
+	if !rec.Front_face {
+		eta = 1 / eta
+	}
+
+	frame := NewOnb(rec.Normal)
+	wo := frame.To_local(Unit_vector(r_in.Direction).Mult(-1))
+
+	return microfacet_dielectric{distribution: d.distribution, eta: eta}, frame, wo
+}
+
+func (d *Rough_dielectric) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	if d.roughness <= 0 {
+		smooth := NewDielectric(d.Ior)
+		return smooth.Sample(r_in, rec)
+	}
+
+	bsdf, frame, wo := d.bsdf(r_in, rec)
+
+	wi, ok := bsdf.sample(wo)
+	if !ok {
+		return Bsdf_sample{}, false
+	}
+
+	pdf := bsdf.pdf(wo, wi)
+	if pdf <= 0 {
+		return Bsdf_sample{}, false
+	}
+
+	reflected, transmitted := bsdf.eval(wo, wi)
+
+	lobe := Bsdf_reflection
+	if wi.Z() < 0 {
+		lobe = Bsdf_transmission
+	}
+
+	weight := (reflected + transmitted) / pdf
+
+	return Bsdf_sample{
+		Direction: frame.Local(wi),
+		Weight:    NewColor(weight, weight, weight),
+		Pdf:       pdf,
+		Flags:     Bsdf_glossy | lobe,
+	}, true
+}
+
+func (d *Rough_dielectric) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+
+	if d.roughness <= 0 {
+		return NewColor(0, 0, 0)
+	}
+
+	bsdf, frame, wo := d.bsdf(r_in, rec)
+	reflected, transmitted := bsdf.eval(wo, frame.To_local(Unit_vector(direction)))
+
+	f := reflected + transmitted
+	return NewColor(f, f, f)
+}
+
+func (d *Rough_dielectric) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+
+	if d.roughness <= 0 {
+		return 0
+	}
+
+	bsdf, frame, wo := d.bsdf(r_in, rec)
+	return bsdf.pdf(wo, frame.To_local(Unit_vector(direction)))
+}
+
+func (d *Rough_dielectric) Flags() Bsdf_flags {
+	if d.roughness <= 0 {
+		return Bsdf_specular | Bsdf_reflection | Bsdf_transmission
+	}
+	return Bsdf_glossy | Bsdf_reflection | Bsdf_transmission
+}
+
+func (d *Rough_dielectric) Emitted(u float64, v float64, p *Point3) Color {
+	return NewColor(0, 0, 0)
+}
+
+func (d *Rough_dielectric) Interior(rec *Hit_record) Medium {
+	return Medium{Sigma_t: d.Absorption}
+}
+
+func (d *Rough_dielectric) Index_of_refraction() float64 {
+	return d.Ior
+}
+
+func (d *Rough_dielectric) Medium_priority() int {
+	return d.Priority
+}
@@ -0,0 +1,127 @@
+package material
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+)
+
+// Subsurface is a translucent material such as wax, skin or plastic, where
+// light enters the surface, scatters around inside and leaves somewhere
+// else. The integrator follows a random walk through the object's interior
+// (see Interior), so it only works on closed meshes with outward normals.
+//
+// At the surface light is reflected specularly with the Fresnel reflectance
+// for the IOR, and otherwise transmitted diffusely into or out of the object.
+type Subsurface struct {
+	tex Texture // color after many scattering events
+	Mfp Color   // mean free path per channel, in scene units
+	Ior float64
+}
+
+func NewSubsurface(c Color, mfp Color, ior float64) Subsurface {
+	tex := NewSolid_color(&c)
+	return Subsurface{tex: &tex, Mfp: mfp, Ior: ior}
+}
+
+func NewTexturedSubsurface(tex Texture, mfp Color, ior float64) Subsurface {
+	return Subsurface{tex: tex, Mfp: mfp, Ior: ior}
+}
+
+// fresnel is the reflectance for light arriving along r_in, total for rays
+// inside that cannot leave
+func (s *Subsurface) fresnel(r_in *Ray, rec *Hit_record) float64 {
+
+	refraction_ratio := s.Ior
+	if rec.Front_face {
+		refraction_ratio = 1.0 / s.Ior
+	}
+
+	cos_theta := math.Min(-Dot(Unit_vector(r_in.Direction), rec.Normal), 1.0)
+	sin_theta := math.Sqrt(math.Max(0, 1.0-cos_theta*cos_theta))
+
+	if refraction_ratio*sin_theta > 1.0 {
+		return 1
+	}
+	return reflectance(cos_theta, refraction_ratio)
+}
+
+func (s *Subsurface) Sample(r_in *Ray, rec *Hit_record) (Bsdf_sample, bool) {
+
+	f := s.fresnel(r_in, rec)
+
+	if rand.Float64() < f {
+		return Bsdf_sample{
+			Direction: Reflect(Unit_vector(r_in.Direction), rec.Normal),
+			Weight:    NewColor(1, 1, 1),
+			Flags:     Bsdf_specular | Bsdf_reflection,
+		}, true
+	}
+
+	// Cross the surface, cosine weighted around the far side of the normal
+	uvw := NewOnb(rec.Normal.Mult(-1))
+	direction := uvw.Local(Random_cosine_direction())
+
+	return Bsdf_sample{
+		Direction: direction,
+		Weight:    NewColor(1, 1, 1),
+		Pdf:       s.Pdf(r_in, rec, direction),
+		Flags:     Bsdf_diffuse | Bsdf_transmission,
+	}, true
+}
+
+// Eval covers the diffuse transmission lobe; for light leaving the object
+// this is what lets lights outside be sampled from its surface
+func (s *Subsurface) Eval(r_in *Ray, rec *Hit_record, direction Vec3) Color {
+	pdf := s.Pdf(r_in, rec, direction)
+	return NewColor(pdf, pdf, pdf)
+}
+
+func (s *Subsurface) Pdf(r_in *Ray, rec *Hit_record, direction Vec3) float64 {
+	cosine := -Dot(Unit_vector(direction), rec.Normal)
+	if cosine <= 0 {
+		return 0
+	}
+	return (1 - s.fresnel(r_in, rec)) * cosine / Pi
+}
+
+// Delta_chance is the chance Sample picks the mirror reflection, see
+// Partly_delta
+func (s *Subsurface) Delta_chance(r_in *Ray, rec *Hit_record) float64 {
+	return s.fresnel(r_in, rec)
+}
+
+func (s *Subsurface) Flags() Bsdf_flags {
+	return Bsdf_diffuse | Bsdf_specular | Bsdf_reflection | Bsdf_transmission
+}
+
+func (s *Subsurface) Emitted(u float64, v float64, p *Point3) Color {
+	return NewColor(0, 0, 0)
+}
+
+// Interior turns the color and mean free path into scattering coefficients.
+// The single scattering albedo is found from the color seen after many
+// bounces with the fit of Chiang et al. (2016).
+func (s *Subsurface) Interior(rec *Hit_record) Medium {
+
+	color := s.tex.Value(rec.U, rec.V, &rec.P)
+	c := color.XYZ()
+	mfp := s.Mfp.XYZ()
+
+	var sigma_t, albedo [3]float64
+	for i := 0; i < 3; i++ {
+		a := math.Max(0, math.Min(0.999, c[i]))
+		k := 4.09712 + 4.20863*a - math.Sqrt(9.59217+41.6808*a+17.7126*a*a)
+		albedo[i] = 1 - k*k
+
+		if mfp[i] > 0 {
+			sigma_t[i] = 1 / mfp[i]
+		}
+	}
+
+	return Medium{
+		Sigma_t: NewColor(sigma_t[0], sigma_t[1], sigma_t[2]),
+		Albedo:  NewColor(albedo[0], albedo[1], albedo[2]),
+	}
+}
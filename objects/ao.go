@@ -0,0 +1,51 @@
+package objects
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Ao_integrator renders ambient occlusion, a clay look that ignores
+// materials and lights. At the first hit Samples cosine weighted rays are
+// cast, and the pixel is the fraction of them that travel Max_distance
+// without hitting anything. Rays that miss the scene are white.
+type Ao_integrator struct {
+	Samples      int     // occlusion rays per camera ray, 16 when not set
+	Max_distance float64 // how far geometry occludes, unlimited when not set
+}
+
+func NewAo_integrator(samples int, max_distance float64) Ao_integrator {
+	return Ao_integrator{Samples: samples, Max_distance: max_distance}
+}
+
+func (ao *Ao_integrator) Li(c *Camera, r *Ray, world Hittable) Color {
+
+	rec, ok := first_hit(r, world)
+	if !ok {
+		return NewColor(1, 1, 1)
+	}
+
+	samples := ao.Samples
+	if samples <= 0 {
+		samples = 16
+	}
+
+	max_distance := ao.Max_distance
+	if max_distance <= 0 {
+		max_distance = Infinity
+	}
+
+	// The normal faces the camera, so this is the visible side
+	uvw := NewOnb(rec.Normal)
+
+	unoccluded := 0
+	for k := 0; k < samples; k++ {
+		direction := uvw.Local(Random_cosine_direction())
+		if !occluded(world, rec.P, direction, max_distance) {
+			unoccluded++
+		}
+	}
+
+	visible := float64(unoccluded) / float64(samples)
+	return NewColor(visible, visible, visible)
+}
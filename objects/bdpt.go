@@ -0,0 +1,638 @@
+package objects
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Bidirectional path tracing. Every sample traces a subpath from the camera
+// and one from a light, then joins every prefix of one to every prefix of the
+// other. Each way (strategy) of forming a path is weighted against all the
+// others that could have produced it with the balance heuristic, so caustics
+// seen through glass are found by the light subpaths while directly visible
+// diffuse lighting still comes from the camera side. Strategies that connect
+// a light subpath straight to the lens land on arbitrary pixels and are
+// splatted onto the film.
+//
+// Connections are straight shadow rays, so the media inside filled objects
+// (see Interior) are not followed: tinted glass renders clear and nested
+// priorities are ignored.
+//
+// The implementation follows the structure of pbrt's BDPT integrator.
+
+type vertex_kind int
+
+const (
+	vertex_camera vertex_kind = iota
+	vertex_light
+	vertex_surface
+	vertex_medium // scattering inside a participating medium
+)
+
+type path_vertex struct {
+	kind    vertex_kind
+	p       Point3
+	n       Vec3       // outward geometric normal, zero for the camera
+	rec     Hit_record // surfaces only
+	beta    Color      // path throughput up to and including this vertex
+	pdf_fwd float64    // area density of sampling this vertex from the previous one
+	pdf_rev float64    // area density of sampling it from the next one instead
+	delta   bool       // scattered by a delta lobe, so it cannot be connected to
+	light   Emitter
+}
+
+func (v *path_vertex) on_surface() bool {
+	return v.kind == vertex_surface || (v.kind == vertex_light && !v.delta_light())
+}
+
+// delta_light reports whether v is on a point or directional light, which no
+// camera path can hit
+func (v *path_vertex) delta_light() bool {
+	_, area := v.light.(*Area_light)
+	return v.kind == vertex_light && !area
+}
+
+func (v *path_vertex) connectible() bool {
+	if v.kind != vertex_surface {
+		return true
+	}
+	return !(*v.rec.Mat).Flags().Is_delta()
+}
+
+// emitted is the radiance a surface vertex sends towards p
+func (v *path_vertex) emitted() Color {
+	m := *v.rec.Mat
+	return m.Emitted(v.rec.U, v.rec.V, &v.rec.P)
+}
+
+// surface_ray rebuilds the hit record of a surface vertex as if it had been
+// reached from direction `from` (pointing away from the vertex)
+func (v *path_vertex) surface_ray(from Vec3) (Ray, Hit_record) {
+	r := NewRay(v.p.Add(from), from.Mult(-1))
+	rec := v.rec
+	rec.Set_face_normal(&r, v.n)
+	return r, rec
+}
+
+// f_cos is the BSDF times cosine at a surface vertex for light travelling
+// between the directions towards from and to
+func (v *path_vertex) f_cos(from Point3, to Point3) Color {
+	r, rec := v.surface_ray(Unit_vector(from.Sub(v.p)))
+	return (*v.rec.Mat).Eval(&r, &rec, to.Sub(v.p))
+}
+
+// convert_density turns a solid angle density at v into an area density at next
+func convert_density(v *path_vertex, pdf float64, next *path_vertex) float64 {
+	w := next.p.Sub(v.p)
+	dist_squared := w.Length_squared()
+	if dist_squared == 0 {
+		return 0
+	}
+
+	pdf /= dist_squared
+	if next.on_surface() {
+		pdf *= math.Abs(Dot(next.n, w.Div(math.Sqrt(dist_squared))))
+	}
+	return pdf
+}
+
+// vertex_pdf is the area density of v sampling next, given it was reached
+// from prev (nil for the camera and lights)
+func (c *Camera) vertex_pdf(v *path_vertex, prev *path_vertex, next *path_vertex) float64 {
+
+	switch v.kind {
+	case vertex_camera:
+		return convert_density(v, c.camera_pdf_dir(v.p, next.p), next)
+	case vertex_light:
+		return c.pdf_light(v, next)
+	}
+
+	r, rec := v.surface_ray(Unit_vector(prev.p.Sub(v.p)))
+	pdf := (*v.rec.Mat).Pdf(&r, &rec, next.p.Sub(v.p))
+
+	return convert_density(v, pdf, next)
+}
+
+// pdf_light is the area density of the light at v emitting towards next
+func (c *Camera) pdf_light(v *path_vertex, next *path_vertex) float64 {
+	if v.light == nil {
+		return 0
+	}
+
+	w := next.p.Sub(v.p)
+	pdf_pos, pdf_dir := v.light.Pdf_le(v.n, w)
+
+	// The parallel rays of a directional light are as dense everywhere
+	// along the beam as on the disk they start from
+	if _, ok := v.light.(*Directional_light); ok {
+		if next.on_surface() {
+			pdf_pos *= math.Abs(Dot(next.n, Unit_vector(w)))
+		}
+		return pdf_pos
+	}
+
+	return convert_density(v, pdf_dir, next)
+}
+
+// pdf_light_origin is the area density of a light subpath starting at v
+func (c *Camera) pdf_light_origin(v *path_vertex) float64 {
+	if v.light == nil {
+		return 0
+	}
+	pdf_pos, _ := v.light.Pdf_le(v.n, v.n)
+	return pdf_pos / float64(len(c.emitters))
+}
+
+// camera_pdf_dir is the solid angle density of the camera generating the ray
+// from the lens point towards p, over the whole image
+func (c *Camera) camera_pdf_dir(lens Point3, p Point3) float64 {
+	_, _, cos_theta, ok := c.raster(lens, p)
+	if !ok {
+		return 0
+	}
+
+	viewport_area := c.pixel_delta_u.Length() * c.pixel_delta_v.Length() * float64(c.Image_width*c.image_height)
+	return c.Focus_dist * c.Focus_dist / (viewport_area * cos_theta * cos_theta * cos_theta)
+}
+
+// raster finds the pixel seen along the line from a lens point through p
+func (c *Camera) raster(lens Point3, p Point3) (i int, j int, cos_theta float64, ok bool) {
+
+	direction := p.Sub(lens)
+	forward := c.w.Mult(-1)
+
+	depth := Dot(direction, forward)
+	if depth <= 0 {
+		return 0, 0, 0, false
+	}
+
+	// Intersect the focus plane, where the viewport lies
+	on_plane := lens.Add(direction.Mult(c.Focus_dist / depth))
+	corner := c.pixel00_loc.Sub(c.pixel_delta_u.Add(c.pixel_delta_v).Mult(0.5))
+	rel := on_plane.Sub(corner)
+
+	x := Dot(rel, c.pixel_delta_u) / c.pixel_delta_u.Length_squared()
+	y := Dot(rel, c.pixel_delta_v) / c.pixel_delta_v.Length_squared()
+
+	if x < 0 || y < 0 || x >= float64(c.Image_width) || y >= float64(c.image_height) {
+		return 0, 0, 0, false
+	}
+
+	return int(x), int(y), depth / direction.Length(), true
+}
+
+// random_walk extends path by following ray through the scene for at most
+// max_depth surface vertices. pdf is the solid angle density with which the
+// last vertex of path sampled ray. If the walk escapes the scene the light
+// the escaping ray brings back from the background is returned.
+func (c *Camera) random_walk(world Hittable, ray Ray, beta Color, pdf float64, max_depth int, path []path_vertex) ([]path_vertex, Color, bool) {
+
+	if max_depth == 0 {
+		return path, NewColor(0, 0, 0), false
+	}
+
+	pdf_fwd := pdf
+
+	for bounces := 0; ; {
+		var rec Hit_record
+
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			return path, ComponentMultiply(beta, c.escaped(&ray, 0)), true
+		}
+
+		outward := rec.Normal
+		if !rec.Front_face {
+			outward = outward.Mult(-1)
+		}
+
+		v := path_vertex{kind: vertex_surface, p: rec.P, n: outward, rec: rec, beta: beta}
+		if (*rec.Mat).Flags()&Bsdf_volume != 0 {
+			v.kind = vertex_medium
+		}
+		v.pdf_fwd = convert_density(&path[len(path)-1], pdf_fwd, &v)
+		if light, ok := c.light_for(rec.Object).(Emitter); ok {
+			v.light = light
+		}
+		path = append(path, v)
+		current := len(path) - 1
+
+		bounces++
+		if bounces >= max_depth {
+			return path, NewColor(0, 0, 0), false
+		}
+
+		m := *rec.Mat
+		bs, ok := m.Sample(&ray, &rec)
+		if !ok {
+			return path, NewColor(0, 0, 0), false
+		}
+
+		beta = ComponentMultiply(beta, bs.Weight)
+		pdf_fwd = bs.Pdf
+
+		// Density of the reverse walk: arriving along the sampled direction
+		// and leaving back towards the previous vertex
+		reverse_in, reverse_rec := path[current].surface_ray(Unit_vector(bs.Direction))
+		pdf_rev := m.Pdf(&reverse_in, &reverse_rec, ray.Direction.Mult(-1))
+
+		if bs.Flags&Bsdf_specular != 0 {
+			path[current].delta = true
+			pdf_fwd = 0
+			pdf_rev = 0
+		}
+
+		path[current-1].pdf_rev = convert_density(&path[current], pdf_rev, &path[current-1])
+
+		ray = scattered_ray(&rec, bs)
+	}
+}
+
+// camera_subpath starts a walk from the camera through pixel (i, j)
+func (c *Camera) camera_subpath(i int, j int, world Hittable, path []path_vertex) ([]path_vertex, Color, bool) {
+
+	ray := c.get_ray(i, j)
+
+	path = append(path, path_vertex{kind: vertex_camera, p: ray.Origin, beta: NewColor(1, 1, 1)})
+	pdf_dir := c.camera_pdf_dir(ray.Origin, ray.Origin.Add(ray.Direction))
+
+	// One more vertex than the light side so the camera can reach a light
+	// after Max_depth bounces
+	return c.random_walk(world, ray, NewColor(1, 1, 1), pdf_dir, c.Max_depth+1, path)
+}
+
+// light_subpath starts a walk from a randomly chosen light
+func (c *Camera) light_subpath(world Hittable, path []path_vertex) []path_vertex {
+
+	if len(c.emitters) == 0 {
+		return path
+	}
+
+	light := c.emitters[rand.Intn(len(c.emitters))]
+	pick_pdf := 1 / float64(len(c.emitters))
+
+	ray, rec, le, pdf_pos, pdf_dir := light.Sample_le()
+	if pdf_pos == 0 || pdf_dir == 0 || le.Near_zero() {
+		return path
+	}
+
+	path = append(path, path_vertex{
+		kind:    vertex_light,
+		p:       rec.P,
+		n:       rec.Normal,
+		rec:     rec,
+		beta:    le,
+		pdf_fwd: pdf_pos * pick_pdf,
+		light:   light,
+	})
+
+	cosine := math.Abs(Dot(rec.Normal, Unit_vector(ray.Direction)))
+	beta := le.Mult(cosine / (pick_pdf * pdf_pos * pdf_dir))
+
+	path, _, _ = c.random_walk(world, ray, beta, pdf_dir, c.Max_depth, path)
+
+	if len(path) > 1 {
+		// The walk takes the direction density as a solid angle, which a
+		// directional light does not have
+		path[1].pdf_fwd = c.pdf_light(&path[0], &path[1])
+
+		// Light does not reach past a surface the light is not linked to
+		if !lights_hit(path[1].rec.Lit_by(), light) {
+			path = path[:1]
+		}
+	}
+
+	return path
+}
+
+// connect joins the first s light and t camera vertices. Light tracing
+// strategies (t == 1) return the pixel they land on with splat set.
+func (c *Camera) connect(world Hittable, light_path []path_vertex, camera_path []path_vertex, s int, t int) (L Color, i int, j int, splat bool) {
+
+	L = NewColor(0, 0, 0)
+
+	// These share the callers' vertices, so nothing may be written through
+	// them. The s == 1 and t == 1 cases swap in a fresh one vertex slice for
+	// the sampled light or lens point, and mis_weight changes its own copies
+	// of the pdfs.
+	lights := light_path[:s]
+	cameras := camera_path[:t]
+
+	switch {
+	case s == 0:
+		// The camera subpath found a light on its own
+		pt := &cameras[t-1]
+		if pt.kind != vertex_surface && pt.kind != vertex_medium {
+			return
+		}
+		if pt.light == nil {
+			// Not a sampled light, so no other strategy could find it
+			return ComponentMultiply(pt.beta, pt.emitted()), 0, 0, false
+		}
+		if !lights_hit(cameras[t-2].rec.Lit_by(), pt.light) {
+			return
+		}
+		L = ComponentMultiply(pt.beta, pt.emitted())
+
+	case t == 1:
+		// Connect a light vertex straight to the lens
+		qs := &lights[s-1]
+		if !qs.connectible() {
+			return
+		}
+
+		lens := c.center
+		if c.Defocus_angle > 0 {
+			lens = c.defocus_disk_sample()
+		}
+
+		var cos_theta float64
+		var ok bool
+		i, j, cos_theta, ok = c.raster(lens, qs.p)
+		if !ok {
+			return
+		}
+
+		direction := lens.Sub(qs.p)
+		distance := direction.Length()
+		if occluded(world, qs.p, direction.Div(distance), distance) {
+			return
+		}
+
+		// Importance of the pixel, divided by the lens density
+		pixel_area := c.pixel_delta_u.Length() * c.pixel_delta_v.Length()
+		we := c.Focus_dist * c.Focus_dist / (pixel_area * cos_theta * cos_theta * cos_theta)
+
+		L = ComponentMultiply(qs.beta, qs.f_cos(lights[s-2].p, lens)).Mult(we / (distance * distance))
+
+		cameras = []path_vertex{{kind: vertex_camera, p: lens, beta: NewColor(1, 1, 1)}}
+		splat = true
+
+	case s == 1:
+		// Sample a point on a light for the end of the camera subpath
+		pt := &cameras[t-1]
+		if !pt.connectible() {
+			return
+		}
+
+		light := c.emitters[rand.Intn(len(c.emitters))]
+		if !lights_hit(pt.rec.Lit_by(), light) {
+			return
+		}
+
+		ls, ok := light.Sample_li(pt.p)
+		if !ok {
+			return
+		}
+
+		sampled := path_vertex{kind: vertex_light, light: light}
+
+		if area, ok := light.(*Area_light); ok {
+			var rec Hit_record
+			r := NewRay(pt.p, ls.Direction)
+			if !area.Shape.Hit(&r, NewInterval(0.001, Infinity), &rec) {
+				return
+			}
+
+			outward := rec.Normal
+			if !rec.Front_face {
+				outward = outward.Mult(-1)
+			}
+			sampled.p, sampled.n, sampled.rec = rec.P, outward, rec
+		} else {
+			// Only the direction towards a directional light matters
+			distance := ls.Distance
+			if distance == Infinity {
+				distance = 1
+			}
+			sampled.p = pt.p.Add(ls.Direction.Mult(distance))
+			sampled.n = ls.Direction.Mult(-1)
+		}
+
+		if occluded(world, pt.p, ls.Direction, ls.Distance) {
+			return
+		}
+
+		sampled.pdf_fwd = c.pdf_light_origin(&sampled)
+
+		pick_pdf := 1 / float64(len(c.emitters))
+		f := pt.f_cos(cameras[t-2].p, sampled.p)
+		L = ComponentMultiply(pt.beta, ComponentMultiply(f, ls.Radiance)).Mult(1 / (ls.Pdf * pick_pdf))
+
+		lights = []path_vertex{sampled}
+
+	default:
+		// Join two surface vertices with a shadow ray
+		qs := &lights[s-1]
+		pt := &cameras[t-1]
+		if !qs.connectible() || !pt.connectible() {
+			return
+		}
+
+		var from_light Point3
+		if s > 1 {
+			from_light = lights[s-2].p
+		}
+
+		direction := pt.p.Sub(qs.p)
+		distance := direction.Length()
+
+		var f_light Color
+		if qs.kind == vertex_light {
+			f_light = qs.beta.Mult(math.Abs(Dot(qs.n, direction.Div(distance))))
+		} else {
+			f_light = ComponentMultiply(qs.beta, qs.f_cos(from_light, pt.p))
+		}
+
+		f_camera := ComponentMultiply(pt.beta, pt.f_cos(cameras[t-2].p, qs.p))
+
+		L = ComponentMultiply(f_light, f_camera).Mult(1 / (distance * distance))
+		if L.Near_zero() {
+			return
+		}
+
+		if occluded(world, qs.p, direction.Div(distance), distance) {
+			return NewColor(0, 0, 0), 0, 0, false
+		}
+	}
+
+	if L.Near_zero() {
+		return NewColor(0, 0, 0), 0, 0, false
+	}
+
+	return L.Mult(c.mis_weight(lights, cameras, light_path, camera_path, s, t)), i, j, splat
+}
+
+// mis_weight is the balance heuristic weight of strategy (s, t). Rather than
+// evaluating every strategy's density from scratch, it walks outwards from
+// the connection multiplying the ratio of reverse to forward densities of
+// each vertex, which gives the density of the neighbouring strategy relative
+// to this one. lights and cameras hold the first s and t vertices, with a
+// vertex sampled by the connection in place of the original endpoint.
+func (c *Camera) mis_weight(lights []path_vertex, cameras []path_vertex, light_path []path_vertex, camera_path []path_vertex, s int, t int) float64 {
+
+	if s+t == 2 {
+		return 1
+	}
+
+	light_fwd := make([]float64, s)
+	light_rev := make([]float64, s)
+	light_delta := make([]bool, s)
+	for k := 0; k < s; k++ {
+		light_fwd[k], light_rev[k], light_delta[k] = lights[k].pdf_fwd, lights[k].pdf_rev, lights[k].delta
+	}
+
+	camera_fwd := make([]float64, t)
+	camera_rev := make([]float64, t)
+	camera_delta := make([]bool, t)
+	for k := 0; k < t; k++ {
+		camera_fwd[k], camera_rev[k], camera_delta[k] = cameras[k].pdf_fwd, cameras[k].pdf_rev, cameras[k].delta
+	}
+
+	var qs, qs_minus, pt, pt_minus *path_vertex
+	if s > 0 {
+		qs = &lights[s-1]
+		light_delta[s-1] = false
+	}
+	if s > 1 {
+		qs_minus = &lights[s-2]
+	}
+	if t > 0 {
+		pt = &cameras[t-1]
+		camera_delta[t-1] = false
+	}
+	if t > 1 {
+		pt_minus = &cameras[t-2]
+	}
+
+	// Reverse densities at the connection for this strategy
+	if pt != nil {
+		if s > 0 {
+			camera_rev[t-1] = c.vertex_pdf(qs, qs_minus, pt)
+		} else {
+			camera_rev[t-1] = c.pdf_light_origin(pt)
+		}
+	}
+	if pt_minus != nil {
+		if s > 0 {
+			camera_rev[t-2] = c.vertex_pdf(pt, qs, pt_minus)
+		} else {
+			camera_rev[t-2] = c.pdf_light(pt, pt_minus)
+		}
+	}
+	if qs != nil {
+		light_rev[s-1] = c.vertex_pdf(pt, pt_minus, qs)
+	}
+	if qs_minus != nil {
+		light_rev[s-2] = c.vertex_pdf(qs, pt, qs_minus)
+	}
+
+	// Delta lobes have no density; their ratios cancel
+	remap := func(pdf float64) float64 {
+		if pdf == 0 {
+			return 1
+		}
+		return pdf
+	}
+
+	sum := 0.0
+
+	ratio := 1.0
+	for k := t - 1; k > 0; k-- {
+		ratio *= remap(camera_rev[k]) / remap(camera_fwd[k])
+		if !camera_delta[k] && !camera_delta[k-1] {
+			sum += ratio
+		}
+	}
+
+	ratio = 1.0
+	for k := s - 1; k >= 0; k-- {
+		ratio *= remap(light_rev[k]) / remap(light_fwd[k])
+		// No camera path can hit a delta light, so strategy s = 0 is
+		// impossible for them
+		delta_before := lights[0].delta_light()
+		if k > 0 {
+			delta_before = light_delta[k-1]
+		}
+		if !light_delta[k] && !delta_before {
+			sum += ratio
+		}
+	}
+
+	return 1 / (1 + sum)
+}
+
+// bdpt_sample traces one bidirectional sample through pixel (i, j), splatting
+// light tracing contributions onto film and returning the rest
+func (c *Camera) bdpt_sample(i int, j int, world Hittable, film *Film, camera_path []path_vertex, light_path []path_vertex) Color {
+
+	// Light arriving from the background is only found by the camera path
+	camera_path, L, _ := c.camera_subpath(i, j, world, camera_path[:0])
+	light_path = c.light_subpath(world, light_path[:0])
+
+	for t := 1; t <= len(camera_path); t++ {
+		for s := 0; s <= len(light_path); s++ {
+
+			depth := s + t - 2
+			if (s == 1 && t == 1) || depth < 0 || depth > c.Max_depth {
+				continue
+			}
+			if s == 1 && len(c.emitters) == 0 {
+				continue
+			}
+
+			contribution, pi, pj, splat := c.connect(world, light_path, camera_path, s, t)
+			if splat {
+				film.Splat(pi, pj, contribution)
+			} else {
+				L = L.Add(contribution)
+			}
+		}
+	}
+
+	return L
+}
+
+// RenderBidirectional renders the scene with bidirectional path tracing and
+// saves the result to output.png
+func (c *Camera) RenderBidirectional(world Hittable) {
+
+	start := time.Now()
+
+	c.initialize()
+	c.Collect_lights(world)
+
+	film := NewFilm(c.Image_width, c.image_height)
+
+	done := make(chan bool)
+	n_processes := 24
+
+	for p := 0; p < n_processes; p++ {
+		go func(start int, end int) {
+			camera_path := make([]path_vertex, 0, c.Max_depth+2)
+			light_path := make([]path_vertex, 0, c.Max_depth+1)
+
+			for j := start; j < end; j++ {
+				for i := 0; i < c.Image_width; i++ {
+					for sample := 0; sample < c.Sample_per_pixel; sample++ {
+						film.Add(i, j, c.bdpt_sample(i, j, world, &film, camera_path, light_path))
+					}
+				}
+			}
+			done <- true
+		}(p*c.image_height/n_processes, (p+1)*c.image_height/n_processes)
+	}
+
+	for p := 0; p < n_processes; p++ {
+		<-done
+	}
+
+	write_png(film.Image(c.Sample_per_pixel), "output.png")
+
+	elapsed := time.Since(start)
+	fmt.Print("~~~~~~~~~~~~~~~~~~~~~~~~~~\nElapsed Time: ", elapsed, "\n~~~~~~~~~~~~~~~~~~~~~~~~~~\n")
+}
@@ -32,7 +32,12 @@ type Camera struct {
 	defocus_disk_u   Vec3
 	defocus_disk_v   Vec3
 	Background       Color
+	Environment      Infinite_light // lights rays leaving the scene in place of Background when set
 	Log_scanlines    bool
+	Lights           []Light    // sampled directly at diffuse hits; collected from the world when nil
+	emitters         []Emitter  // the Lights that paths can start from
+	Roulette_depth   int        // bounces before Russian roulette may end a path, 0 disables it
+	Integrator       Integrator // computes the light along camera rays, the path tracer when nil
 	// SkySphere        Sphere
 }
 
@@ -45,6 +50,7 @@ func NewCamera() Camera {
 
 	c.Sample_per_pixel = 300 //250
 	c.Max_depth = 30         //50
+	c.Roulette_depth = 3
 
 	c.Vfov = 60
 	c.Look_from = NewPoint3(13, 11, -4)
@@ -95,15 +101,38 @@ func (c *Camera) initialize() {
 
 	defocus_radius := c.Focus_dist * math.Tan(Degrees_to_radians(c.Defocus_angle/2))
 	c.defocus_disk_u = c.u.Mult(defocus_radius)
-	c.defocus_disk_u = c.v.Mult(defocus_radius)
+	c.defocus_disk_v = c.v.Mult(defocus_radius)
 
 }
 
+// Collect_lights gathers the emissive objects of world and the environment
+// into Lights, unless lights were already provided. Set Lights to an empty
+// slice to disable direct light sampling.
+func (c *Camera) Collect_lights(world Hittable) {
+	if c.Lights == nil {
+		c.Lights = Find_lights(world)
+		if c.Environment != nil {
+			c.Lights = append(c.Lights, c.Environment)
+		}
+	}
+
+	c.emitters = nil
+	for _, light := range c.Lights {
+		if directional, ok := light.(*Directional_light); ok {
+			directional.fit_scene(scene_bounds(world))
+		}
+		if emitter, ok := light.(Emitter); ok {
+			c.emitters = append(c.emitters, emitter)
+		}
+	}
+}
+
 func (c *Camera) Render(world Hittable) {
 
 	start := time.Now() //time execution
 
 	c.initialize()
+	c.Collect_lights(world)
 
 	img := image.NewRGBA(image.Rect(0, 0, c.Image_width, c.image_height))
 
@@ -164,6 +193,7 @@ func (c *Camera) Render(world Hittable) {
 // This is used for WASM builds where we can't write to files
 func (c *Camera) RenderToBuffer(world Hittable) []byte {
 	c.initialize()
+	c.Collect_lights(world)
 
 	// Create pixel buffer (RGBA format for HTML canvas)
 	pixels := make([]byte, c.Image_width*c.image_height*4)
@@ -194,6 +224,7 @@ func (c *Camera) RenderToBuffer(world Hittable) []byte {
 // This creates a "reveal" effect where the image progressively fills in
 func (c *Camera) RenderProgressiveWASM(world Hittable, pixels []byte, updateCallback func(), updatePercent int) {
 	c.initialize()
+	c.Collect_lights(world)
 	totalPixels := c.Image_width * c.image_height
 
 	// Create shuffled pixel indices using Fisher-Yates algorithm
@@ -297,6 +328,7 @@ func (c *Camera) RenderMultithreaded(world Hittable) {
 	start := time.Now()
 
 	c.initialize()
+	c.Collect_lights(world)
 	img := image.NewRGBA(image.Rect(0, 0, c.Image_width, c.image_height))
 
 	done := make(chan bool)
@@ -328,44 +360,345 @@ func (c *Camera) RenderMultithreaded(world Hittable) {
 
 }
 
+// RenderConvergence renders the scene one sample per pixel at a time and
+// returns n_frames snapshots of the accumulated image, spaced geometrically so
+// the early noisy passes are well represented. The frames can be handed to
+// Encode_gif or Encode_apng to produce a convergence animation.
+func (c *Camera) RenderConvergence(world Hittable, n_frames int) []*image.RGBA {
+
+	c.initialize()
+	c.Collect_lights(world)
+
+	if n_frames < 1 {
+		n_frames = 1
+	}
+
+	accumulator := make([]Color, c.Image_width*c.image_height)
+	var frames []*image.RGBA
+
+	// The last frame is always the fully converged image
+	next_frame := c.Sample_per_pixel
+	if n_frames > 1 {
+		next_frame = 1
+	}
+
+	for pass := 1; pass <= c.Sample_per_pixel; pass++ {
+
+		done := make(chan bool)
+		n_processes := 24
+
+		for p := 0; p < n_processes; p++ {
+			go func(start int, end int) {
+				for j := start; j < end; j++ {
+					for i := 0; i < c.Image_width; i++ {
+						r := c.get_ray(i, j)
+						idx := j*c.Image_width + i
+						accumulator[idx] = accumulator[idx].Add(ray_color(c, &r, c.Max_depth, world))
+					}
+				}
+				done <- true
+			}(p*c.image_height/n_processes, (p+1)*c.image_height/n_processes)
+		}
+
+		for p := 0; p < n_processes; p++ {
+			<-done
+		}
+
+		if pass < next_frame {
+			continue
+		}
+
+		img := image.NewRGBA(image.Rect(0, 0, c.Image_width, c.image_height))
+		for j := 0; j < c.image_height; j++ {
+			for i := 0; i < c.Image_width; i++ {
+				Write_color(accumulator[j*c.Image_width+i], pass, img, i, j)
+			}
+		}
+		frames = append(frames, img)
+
+		// Spread the remaining snapshots geometrically over the remaining passes
+		remaining := n_frames - len(frames)
+		ratio := math.Pow(float64(c.Sample_per_pixel)/float64(pass), 1.0/float64(remaining))
+		next_frame = int(math.Max(float64(pass+1), math.Round(float64(pass)*ratio)))
+		if remaining <= 1 || next_frame > c.Sample_per_pixel {
+			next_frame = c.Sample_per_pixel
+		}
+	}
+
+	return frames
+}
+
 func ray_color(c *Camera, r *Ray, depth int, world Hittable) Color {
-	var rec Hit_record
+	if c.Integrator != nil {
+		return c.Integrator.Li(c, r, world)
+	}
+	return trace(c, r, depth, world)
+}
+
+// trace follows a path through the scene for at most depth bounces, adding
+// the light found at each vertex scaled by the path throughput so far
+func trace(c *Camera, r *Ray, depth int, world Hittable) Color {
+	path := rgb_path{radiance: NewColor(0, 0, 0), throughput: NewColor(1, 1, 1)}
+	follow_path(c, r, depth, world, &path, nil)
+	return path.radiance
+
+	// unit_direction := Unit_vector(r.Direction)
+	// a := 0.5 * (unit_direction.Y() + 1.0)
+	// return NewColor(1.0, 1.0, 1.0).Mult(1.0 - a).Add(NewColor(0.5, 0.7, 1.0).Mult(a))
+}
+
+// path_light is the light a path carries: RGB for the path tracer, or a few
+// wavelengths for the spectral one. Materials and lights work in RGB, so
+// colors met along the way are converted as they are added.
+type path_light interface {
+	add(light Color)        // adds light reaching the current vertex
+	attenuate(weight Color) // scales the throughput
+	scatter(bs Bsdf_sample) // scales the throughput by a BSDF sample's weight
+	roulette() bool         // plays Russian roulette, false when the path ends
+	wavelength() float64    // of rays along the path, zero for RGB
+}
+
+// rgb_path carries RGB light
+type rgb_path struct {
+	radiance   Color
+	throughput Color
+}
+
+func (p *rgb_path) add(light Color) {
+	p.radiance = p.radiance.Add(ComponentMultiply(p.throughput, light))
+}
+
+func (p *rgb_path) attenuate(weight Color) {
+	p.throughput = ComponentMultiply(p.throughput, weight)
+}
+
+func (p *rgb_path) scatter(bs Bsdf_sample) {
+	p.attenuate(bs.Weight)
+}
+
+func (p *rgb_path) roulette() bool {
+	survival := math.Min(1, math.Max(p.throughput.X(), math.Max(p.throughput.Y(), p.throughput.Z())))
+	if rand.Float64() >= survival {
+		return false
+	}
+	p.throughput = p.throughput.Div(survival)
+	return true
+}
+
+func (p *rgb_path) wavelength() float64 {
+	return 0
+}
+
+// follow_path is the path tracing loop shared by the integrators. After
+// Roulette_depth bounces dim paths are ended at random, with survivors
+// boosted to keep the estimate unbiased. With caustics set, light reaching a
+// diffuse hit through specular bounces comes from the photon map instead.
+func follow_path(c *Camera, r *Ray, depth int, world Hittable, path path_light, caustics *caustic_estimate) {
+
+	ray := *r
+	ray.Wavelength = path.wavelength()
+
+	// Density with which the previous bounce sampled ray, or zero when it could
+	// not have been found by light sampling (camera rays and delta lobes). In
+	// that case emission is counted in full, otherwise it is weighted against
+	// the light sampling strategy with the power heuristic.
+	bsdf_pdf := 0.0
+
+	// Filled objects the path is inside
+	var inside media
+
+	// Lights linked to the previous hit, whose emission found by the BSDF
+	// sample is dropped like its light samples when not linked
+	var lit_by []Hittable
+
+	// For the caustics: whether the path left its last non-delta surface by
+	// a lobe that the photons gathered there stand for, and whether it has
+	// only passed delta materials since. A delta lobe of a partly delta
+	// material, like a mirror in a Mix, is not gathered, so light found
+	// through it still counts.
+	gathered := false
+	delta_since := false
+
+	for bounce := 0; bounce < depth; bounce++ {
+		var rec Hit_record
+
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			path.add(c.escaped(&ray, bsdf_pdf))
+			break
+		}
+
+		if len(inside) > 0 {
+			sampled := ray
+
+			var weight Color
+			var ok bool
+			ray, rec, inside, weight, ok = cross_media(world, ray, rec, inside)
+			if !ok {
+				break
+			}
+			path.attenuate(weight)
+			ray.Wavelength = path.wavelength()
 
-	if depth <= 0 {
+			// A shadow ray cannot scatter in the medium or pass an ignored
+			// surface, so emission found after either is counted in full
+			if ray.Origin != sampled.Origin || ray.Direction != sampled.Direction {
+				bsdf_pdf = 0
+			}
+		}
+
+		m := *rec.Mat
+
+		light := c.light_for(rec.Object)
+
+		// The photons already carry light that reaches a light this way
+		if !(caustics != nil && light != nil && gathered && delta_since) {
+			color_from_emission := m.Emitted(rec.U, rec.V, &rec.P)
+
+			if light != nil {
+				if !lights_hit(lit_by, light) {
+					color_from_emission = NewColor(0, 0, 0)
+				} else if bsdf_pdf > 0 {
+					light_pdf := light.Pdf_li(ray.Origin, ray.Direction) / float64(len(c.Lights))
+					color_from_emission = color_from_emission.Mult(Power_heuristic(bsdf_pdf, light_pdf))
+				}
+			}
+
+			path.add(color_from_emission)
+		}
+
+		// Light sampling only helps lobes that can be evaluated
+		if !m.Flags().Is_delta() {
+			if len(c.Lights) > 0 {
+				path.add(c.sample_lights(&ray, &rec, m, world, inside))
+			}
+
+			if caustics != nil {
+				path.add(caustics.photons.Radiance(&ray, &rec, m, caustics.radius))
+			}
+		}
+
+		bs, ok := m.Sample(&ray, &rec)
+		if !ok {
+			break
+		}
+
+		path.scatter(bs)
+
+		// Crossing the surface of a filled object enters or leaves its medium
+		if filled, ok := m.(Interior); ok && bs.Flags&Bsdf_transmission != 0 {
+			inside = inside.cross(m, filled, &rec)
+		}
+
+		specular := bs.Flags&Bsdf_specular != 0
+		if m.Flags().Is_delta() {
+			delta_since = true
+		} else {
+			gathered = !specular
+			delta_since = false
+		}
+
+		// Delta lobes cannot be found by light sampling, so the next hit keeps
+		// its full emission
+		bsdf_pdf = bs.Pdf
+		if specular || len(c.Lights) == 0 {
+			bsdf_pdf = 0
+		}
+
+		if c.Roulette_depth > 0 && bounce+1 >= c.Roulette_depth && !path.roulette() {
+			break
+		}
+
+		lit_by = rec.Lit_by()
+		ray = scattered_ray(&rec, bs)
+		ray.Wavelength = path.wavelength()
+	}
+}
+
+// sample_lights estimates the light arriving directly at a hit by picking one
+// light at random and tracing a shadow ray towards it. The contribution is
+// weighted against the chance of the BSDF sampling the same direction, and
+// dimmed by the medium the shadow ray crosses when the hit is inside a filled
+// object.
+func (c *Camera) sample_lights(r *Ray, rec *Hit_record, m Material, world Hittable, inside media) Color {
+
+	light := c.Lights[rand.Intn(len(c.Lights))]
+	if !lights_hit(rec.Lit_by(), light) {
 		return NewColor(0, 0, 0)
 	}
 
-	if !world.Hit(r, NewInterval(0.001, Infinity), &rec) {
-		return c.Background
+	ls, ok := light.Sample_li(rec.P)
+	if !ok {
+		return NewColor(0, 0, 0)
+	}
+
+	f := m.Eval(r, rec, ls.Direction)
+	if f.Near_zero() {
+		return NewColor(0, 0, 0)
+	}
 
-		// return c.SkySphere.Mat.
+	if occluded(world, rec.P, ls.Direction, ls.Distance) {
+		return NewColor(0, 0, 0)
+	}
 
+	// The light was chosen with probability 1/n. BSDF sampling can never
+	// reach a delta light, so light sampling takes its full weight.
+	light_pdf := ls.Pdf / float64(len(c.Lights))
+	weight := 1.0
+	if !ls.Delta {
+		weight = Power_heuristic(light_pdf, m.Pdf(r, rec, ls.Direction))
 	}
 
-	// direction := rec.Normal.Add(Random_unit_vector())
+	transmittance := inside.beyond(m, rec, ls.Direction).transmittance(ls.Distance)
 
-	// return ray_color(NewRay(rec.P, direction), depth-1, world).Mult(0.50)
+	return ComponentMultiply(ComponentMultiply(f, ls.Radiance), transmittance).Mult(weight / light_pdf)
+}
 
-	var scattered Ray
-	var attenuation Color
+// light_for returns the light sampling object, or nil if it is not a light
+func (c *Camera) light_for(object Hittable) Light {
+	if object == nil {
+		return nil
+	}
+
+	for _, light := range c.Lights {
+		if area, ok := light.(*Area_light); ok && Hittable(area.Shape) == object {
+			return light
+		}
+	}
 
-	m := *rec.Mat
+	return nil
+}
 
-	color_from_emission := m.Emitted(rec.U, rec.V, &rec.P)
+// escaped is the light arriving along a ray that left the scene: the
+// environment if there is one, or else the Background color. bsdf_pdf is
+// the density with which the ray was sampled, zero if light sampling could
+// not have chosen it.
+func (c *Camera) escaped(ray *Ray, bsdf_pdf float64) Color {
 
-	if !m.Scatter(r, &rec, &attenuation, &scattered) {
-		return color_from_emission
+	if c.Environment == nil {
+		return c.Background
 	}
 
-	// if rec.Mat.Scatter(r, &rec, &attenuation, &scattered) {
-	color_from_scatter := ComponentMultiply(attenuation, ray_color(c, &scattered, depth-1, world))
-	// }
+	le := c.Environment.Le(ray.Direction)
 
-	return color_from_emission.Add(color_from_scatter)
+	if bsdf_pdf > 0 {
+		for _, light := range c.Lights {
+			if light == Light(c.Environment) {
+				light_pdf := c.Environment.Pdf_li(ray.Origin, ray.Direction) / float64(len(c.Lights))
+				le = le.Mult(Power_heuristic(bsdf_pdf, light_pdf))
+				break
+			}
+		}
+	}
 
-	// unit_direction := Unit_vector(r.Direction)
-	// a := 0.5 * (unit_direction.Y() + 1.0)
-	// return NewColor(1.0, 1.0, 1.0).Mult(1.0 - a).Add(NewColor(0.5, 0.7, 1.0).Mult(a))
+	return le
+}
+
+// occluded reports whether anything lies between origin and the point at
+// distance along direction
+func occluded(world Hittable, origin Point3, direction Vec3, distance float64) bool {
+	shadow_ray := NewRay(origin, direction)
+	shadow_ray.Kind = Ray_shadow
+	return world.Occluded(&shadow_ray, NewInterval(0.001, distance*(1-1e-4)))
 }
 
 func (c *Camera) get_ray(i int, j int) Ray {
@@ -381,7 +714,29 @@ func (c *Camera) get_ray(i int, j int) Ray {
 
 	ray_direction := pixel_sample.Sub(ray_origin)
 
-	return NewRay(ray_origin, ray_direction)
+	ray := NewRay(ray_origin, ray_direction)
+	ray.Kind = Ray_camera
+	return ray
+}
+
+// scattered_ray continues a path from a hit in a sampled direction, as the
+// kind of ray that matches the sampled lobe
+func scattered_ray(rec *Hit_record, bs Bsdf_sample) Ray {
+
+	ray := NewRay(rec.P, bs.Direction)
+
+	switch {
+	case bs.Flags&Bsdf_volume != 0:
+		ray.Kind = Ray_diffuse
+	case bs.Flags&Bsdf_transmission != 0:
+		ray.Kind = Ray_transmission
+	case bs.Flags&Bsdf_diffuse != 0:
+		ray.Kind = Ray_diffuse
+	default:
+		ray.Kind = Ray_reflection
+	}
+
+	return ray
 }
 
 func (c *Camera) defocus_disk_sample() Point3 {
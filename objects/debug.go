@@ -0,0 +1,164 @@
+package objects
+
+import (
+	"math"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Debug integrators show one property of the first surface each camera ray
+// hits, so problems with geometry or textures can be spotted in a fraction of
+// the time of a full render. Rays that miss everything are black.
+
+// Normals_integrator shows outward normals, each axis mapped from [-1, 1] to
+// [0, 1]. A normal pointing straight at +y is light green.
+type Normals_integrator struct{}
+
+func (d *Normals_integrator) Li(c *Camera, r *Ray, world Hittable) Color {
+	rec, ok := first_hit(r, world)
+	if !ok {
+		return NewColor(0, 0, 0)
+	}
+
+	// Undo the flip towards the ray so inside out surfaces stand out
+	normal := rec.Normal
+	if !rec.Front_face {
+		normal = normal.Mult(-1)
+	}
+
+	return debug_color(normal.Add(NewVec3(1, 1, 1)).Mult(0.5))
+}
+
+// Facing_integrator shows the fronts of surfaces in blue and their backs in
+// red. Closed meshes seen from outside should be entirely blue.
+type Facing_integrator struct{}
+
+func (d *Facing_integrator) Li(c *Camera, r *Ray, world Hittable) Color {
+	rec, ok := first_hit(r, world)
+	if !ok {
+		return NewColor(0, 0, 0)
+	}
+
+	if rec.Front_face {
+		return debug_color(NewColor(0.1, 0.3, 1))
+	}
+	return debug_color(NewColor(1, 0.1, 0.1))
+}
+
+// Uv_integrator shows texture coordinates, u in red and v in green
+type Uv_integrator struct{}
+
+func (d *Uv_integrator) Li(c *Camera, r *Ray, world Hittable) Color {
+	rec, ok := first_hit(r, world)
+	if !ok {
+		return NewColor(0, 0, 0)
+	}
+
+	return debug_color(NewColor(rec.U, rec.V, 0))
+}
+
+// Barycentric_integrator shows the barycentric coordinates of triangle hits
+// as the weights of their three corners. Other shapes are grey.
+type Barycentric_integrator struct{}
+
+func (d *Barycentric_integrator) Li(c *Camera, r *Ray, world Hittable) Color {
+	rec, ok := first_hit(r, world)
+	if !ok {
+		return NewColor(0, 0, 0)
+	}
+
+	if _, is_tri := rec.Object.(*Tri); !is_tri {
+		return debug_color(NewColor(0.2, 0.2, 0.2))
+	}
+
+	// Triangles are parametrised from their first corner along two edges
+	return debug_color(NewColor(1-rec.U-rec.V, rec.U, rec.V))
+}
+
+// Depth_integrator shows the distance along the view direction, white at the
+// camera fading to black at Max_distance, or twice the focus distance when
+// that is not set
+type Depth_integrator struct {
+	Max_distance float64
+}
+
+func (d *Depth_integrator) Li(c *Camera, r *Ray, world Hittable) Color {
+	rec, ok := first_hit(r, world)
+	if !ok {
+		return NewColor(0, 0, 0)
+	}
+
+	max_distance := d.Max_distance
+	if max_distance <= 0 {
+		max_distance = 2 * c.Focus_dist
+	}
+
+	depth := Dot(rec.P.Sub(c.center), c.w.Mult(-1))
+	shade := math.Max(0, 1-depth/max_distance)
+
+	return debug_color(NewColor(shade, shade, shade))
+}
+
+// Bvh_heatmap shows how many BVH nodes, or with Primitives set how many
+// primitives, each camera ray was tested against. Colors run from blue for
+// none through green and yellow to red at Max_count or more (default 64).
+type Bvh_heatmap struct {
+	Max_count  int
+	Primitives bool
+}
+
+func (d *Bvh_heatmap) Li(c *Camera, r *Ray, world Hittable) Color {
+
+	var stats Traversal_stats
+	counted := *r
+	counted.Stats = &stats
+
+	var rec Hit_record
+	world.Hit(&counted, NewInterval(0.001, Infinity), &rec)
+
+	count := stats.Nodes
+	if d.Primitives {
+		count = stats.Primitives
+	}
+
+	max_count := d.Max_count
+	if max_count <= 0 {
+		max_count = 64
+	}
+
+	return debug_color(heat(float64(count) / float64(max_count)))
+}
+
+// heat maps [0, 1] onto a blue, cyan, green, yellow, red ramp
+func heat(t float64) Color {
+
+	ramp := []Color{
+		NewColor(0, 0, 1),
+		NewColor(0, 1, 1),
+		NewColor(0, 1, 0),
+		NewColor(1, 1, 0),
+		NewColor(1, 0, 0),
+	}
+
+	t = math.Max(0, math.Min(1, t)) * float64(len(ramp)-1)
+	k := int(t)
+	if k == len(ramp)-1 {
+		return ramp[k]
+	}
+
+	f := t - float64(k)
+	return ramp[k].Mult(1 - f).Add(ramp[k+1].Mult(f))
+}
+
+func first_hit(r *Ray, world Hittable) (Hit_record, bool) {
+	var rec Hit_record
+	hit := world.Hit(r, NewInterval(0.001, Infinity), &rec)
+	return rec, hit
+}
+
+// debug_color squares a color so it shows as given after the gamma
+// correction Write_color applies
+func debug_color(c Color) Color {
+	return ComponentMultiply(c, c)
+}
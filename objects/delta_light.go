@@ -0,0 +1,254 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Point_light, Spot_light and Directional_light are delta lights: they shine
+// from a single point or along a single direction, so scattered rays can never
+// hit them and they are only reached by shadow rays. They are Hittables that
+// nothing hits, so they can be added to the world like any other object and
+// Find_lights will collect them. Their Sample_le gives the position density
+// of a point light and the direction density of a directional light as 1,
+// while Pdf_le gives them as 0 since no other strategy can produce them.
+
+// Point_light shines equally in every direction. Intensity is the radiant
+// intensity, so the light arriving at distance d is Intensity / d^2.
+type Point_light struct {
+	Position  Point3
+	Intensity Color
+}
+
+func NewPoint_light(position Point3, intensity Color) Point_light {
+	return Point_light{Position: position, Intensity: intensity}
+}
+
+func (l *Point_light) Sample_li(p Point3) (Light_sample, bool) {
+	return point_sample(p, l.Position, l.Intensity)
+}
+
+func (l *Point_light) Pdf_li(p Point3, direction Vec3) float64 {
+	return 0
+}
+
+// Sample_le shines a ray in a uniformly random direction. The record holds
+// the position, with the normal along the ray so its cosine is one.
+func (l *Point_light) Sample_le() (ray Ray, rec Hit_record, le Color, pdf_pos float64, pdf_dir float64) {
+	direction := Random_unit_vector()
+	rec = Hit_record{P: l.Position, Normal: direction}
+	return NewRay(l.Position, direction), rec, l.Intensity, 1, 1 / (4 * Pi)
+}
+
+func (l *Point_light) Pdf_le(normal Vec3, direction Vec3) (pdf_pos float64, pdf_dir float64) {
+	return 0, 1 / (4 * Pi)
+}
+
+func (l *Point_light) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+	return false
+}
+
+func (l *Point_light) Occluded(r *Ray, ray_t Interval) bool {
+	return false
+}
+
+func (l *Point_light) Bounding_box() Aabb {
+	return NewAabbFromPoints(l.Position, l.Position)
+}
+
+// Spot_light is a point light limited to a cone around the direction it
+// points in. It has full intensity up to Falloff_angle from the axis and fades
+// smoothly to nothing at Cone_angle, both in degrees.
+type Spot_light struct {
+	Position      Point3
+	Direction     Vec3
+	Intensity     Color
+	Cone_angle    float64
+	Falloff_angle float64
+	cos_cone      float64
+	cos_falloff   float64
+}
+
+func NewSpot_light(position Point3, look_at Point3, intensity Color, cone_angle float64, falloff_angle float64) Spot_light {
+
+	falloff_angle = math.Min(falloff_angle, cone_angle)
+
+	return Spot_light{
+		Position:      position,
+		Direction:     Unit_vector(look_at.Sub(position)),
+		Intensity:     intensity,
+		Cone_angle:    cone_angle,
+		Falloff_angle: falloff_angle,
+		cos_cone:      math.Cos(Degrees_to_radians(cone_angle)),
+		cos_falloff:   math.Cos(Degrees_to_radians(falloff_angle)),
+	}
+}
+
+func (l *Spot_light) Sample_li(p Point3) (Light_sample, bool) {
+
+	ls, ok := point_sample(p, l.Position, l.Intensity)
+	if !ok {
+		return ls, false
+	}
+
+	falloff := l.falloff(Dot(ls.Direction.Mult(-1), l.Direction))
+	if falloff <= 0 {
+		return Light_sample{}, false
+	}
+
+	ls.Radiance = ls.Radiance.Mult(falloff)
+	return ls, true
+}
+
+// falloff scales the intensity for a direction at the given cosine from the
+// axis, easing between the falloff and cone angles
+func (l *Spot_light) falloff(cos_theta float64) float64 {
+	if cos_theta <= l.cos_cone {
+		return 0
+	}
+	if cos_theta >= l.cos_falloff {
+		return 1
+	}
+	t := (cos_theta - l.cos_cone) / (l.cos_falloff - l.cos_cone)
+	return t * t * (3 - 2*t)
+}
+
+func (l *Spot_light) Pdf_li(p Point3, direction Vec3) float64 {
+	return 0
+}
+
+// Sample_le shines a ray in a uniformly random direction within the cone
+func (l *Spot_light) Sample_le() (ray Ray, rec Hit_record, le Color, pdf_pos float64, pdf_dir float64) {
+
+	cos_theta := 1 - rand.Float64()*(1-l.cos_cone)
+	sin_theta := math.Sqrt(math.Max(0, 1-cos_theta*cos_theta))
+	phi := 2 * Pi * rand.Float64()
+
+	uvw := NewOnb(l.Direction)
+	direction := uvw.Local(NewVec3(math.Cos(phi)*sin_theta, math.Sin(phi)*sin_theta, cos_theta))
+
+	rec = Hit_record{P: l.Position, Normal: direction}
+	le = l.Intensity.Mult(l.falloff(cos_theta))
+
+	return NewRay(l.Position, direction), rec, le, 1, 1 / (2 * Pi * (1 - l.cos_cone))
+}
+
+func (l *Spot_light) Pdf_le(normal Vec3, direction Vec3) (pdf_pos float64, pdf_dir float64) {
+	if Dot(Unit_vector(direction), l.Direction) <= l.cos_cone {
+		return 0, 0
+	}
+	return 0, 1 / (2 * Pi * (1 - l.cos_cone))
+}
+
+func (l *Spot_light) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+	return false
+}
+
+func (l *Spot_light) Occluded(r *Ray, ray_t Interval) bool {
+	return false
+}
+
+func (l *Spot_light) Bounding_box() Aabb {
+	return NewAabbFromPoints(l.Position, l.Position)
+}
+
+// Directional_light is infinitely far away, like the sun, so light arrives
+// from the same direction everywhere. Direction is the way the light travels
+// and Irradiance the light falling on a surface facing it.
+type Directional_light struct {
+	Direction    Vec3
+	Irradiance   Color
+	scene_center Point3  // centre and radius of a sphere around the scene,
+	scene_radius float64 // which rays from Sample_le start just outside of
+}
+
+func NewDirectional_light(direction Vec3, irradiance Color) Directional_light {
+	return Directional_light{Direction: Unit_vector(direction), Irradiance: irradiance}
+}
+
+func (l *Directional_light) Sample_li(p Point3) (Light_sample, bool) {
+	return Light_sample{
+		Direction: l.Direction.Mult(-1),
+		Distance:  Infinity,
+		Radiance:  l.Irradiance,
+		Pdf:       1,
+		Delta:     true,
+	}, true
+}
+
+func (l *Directional_light) Pdf_li(p Point3, direction Vec3) float64 {
+	return 0
+}
+
+// fit_scene sizes the beam Sample_le shines to cover the given bounds
+func (l *Directional_light) fit_scene(bounds Aabb) {
+	x, y, z := bounds.Axis_interval(0), bounds.Axis_interval(1), bounds.Axis_interval(2)
+	if x.Size() < 0 || y.Size() < 0 || z.Size() < 0 {
+		l.scene_radius = 0
+		return
+	}
+
+	min := NewPoint3(x.Min, y.Min, z.Min)
+	max := NewPoint3(x.Max, y.Max, z.Max)
+	l.scene_center = min.Add(max).Mult(0.5)
+	l.scene_radius = max.Sub(min).Length() / 2
+}
+
+// Sample_le picks a ray of the parallel beam from a disk facing the light,
+// as wide as the scene and just outside it
+func (l *Directional_light) Sample_le() (ray Ray, rec Hit_record, le Color, pdf_pos float64, pdf_dir float64) {
+
+	if l.scene_radius == 0 {
+		return Ray{}, Hit_record{}, Color{}, 0, 0
+	}
+
+	uvw := NewOnb(l.Direction)
+	disk := Random_in_unit_disk()
+	origin := l.scene_center.Add(uvw.Local(NewVec3(disk.X(), disk.Y(), -1)).Mult(l.scene_radius))
+
+	rec = Hit_record{P: origin, Normal: l.Direction}
+	pdf_pos, _ = l.Pdf_le(l.Direction, l.Direction)
+
+	return NewRay(origin, l.Direction), rec, l.Irradiance, pdf_pos, 1
+}
+
+func (l *Directional_light) Pdf_le(normal Vec3, direction Vec3) (pdf_pos float64, pdf_dir float64) {
+	if l.scene_radius == 0 {
+		return 0, 0
+	}
+	return 1 / (Pi * l.scene_radius * l.scene_radius), 0
+}
+
+func (l *Directional_light) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+	return false
+}
+
+func (l *Directional_light) Occluded(r *Ray, ray_t Interval) bool {
+	return false
+}
+
+// Bounding_box is empty, since the light has no place
+func (l *Directional_light) Bounding_box() Aabb {
+	return NewAabb(Empty, Empty, Empty)
+}
+
+// point_sample is the light from a point source of the given intensity
+func point_sample(p Point3, position Point3, intensity Color) (Light_sample, bool) {
+
+	to_light := position.Sub(p)
+	distance_squared := to_light.Length_squared()
+	if distance_squared == 0 {
+		return Light_sample{}, false
+	}
+
+	return Light_sample{
+		Direction: to_light.Div(math.Sqrt(distance_squared)),
+		Distance:  math.Sqrt(distance_squared),
+		Radiance:  intensity.Div(distance_squared),
+		Pdf:       1,
+		Delta:     true,
+	}, true
+}
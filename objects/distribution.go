@@ -0,0 +1,110 @@
+package objects
+
+import (
+	"sort"
+)
+
+// piecewise_1d is a piecewise constant density over [0, 1) proportional to
+// n non negative values, sampled by inverting its cumulative distribution
+type piecewise_1d struct {
+	values   []float64
+	cdf      []float64
+	integral float64
+}
+
+func new_piecewise_1d(values []float64) piecewise_1d {
+
+	n := len(values)
+	cdf := make([]float64, n+1)
+
+	for i, value := range values {
+		cdf[i+1] = cdf[i] + value/float64(n)
+	}
+
+	integral := cdf[n]
+
+	// With nothing to go on every part is equally likely
+	if integral == 0 {
+		for i := 1; i <= n; i++ {
+			cdf[i] = float64(i) / float64(n)
+		}
+	} else {
+		for i := 1; i <= n; i++ {
+			cdf[i] /= integral
+		}
+	}
+
+	return piecewise_1d{values: values, cdf: cdf, integral: integral}
+}
+
+// sample maps u in [0, 1) to a point x in [0, 1), returning the density at x
+// and the index of the piece x falls in
+func (d *piecewise_1d) sample(u float64) (x float64, pdf float64, index int) {
+
+	n := len(d.values)
+
+	// Last piece whose cdf starts at or below u
+	index = sort.Search(n, func(i int) bool { return d.cdf[i+1] > u })
+	index = min(index, n-1)
+
+	du := u - d.cdf[index]
+	if width := d.cdf[index+1] - d.cdf[index]; width > 0 {
+		du /= width
+	}
+
+	return (float64(index) + du) / float64(n), d.pdf(index), index
+}
+
+// pdf is the density of the piece with the given index
+func (d *piecewise_1d) pdf(index int) float64 {
+	if d.integral == 0 {
+		return 1
+	}
+	return d.values[index] / d.integral
+}
+
+// piecewise_2d is a piecewise constant density over the unit square from a
+// grid of values, sampled by picking a row from the marginal density of the
+// rows and then a column within it
+type piecewise_2d struct {
+	rows     []piecewise_1d
+	marginal piecewise_1d
+}
+
+// new_piecewise_2d takes width * height values, row by row
+func new_piecewise_2d(values []float64, width int, height int) piecewise_2d {
+
+	rows := make([]piecewise_1d, height)
+	totals := make([]float64, height)
+
+	for j := range rows {
+		rows[j] = new_piecewise_1d(values[j*width : (j+1)*width])
+		totals[j] = rows[j].integral
+	}
+
+	return piecewise_2d{rows: rows, marginal: new_piecewise_1d(totals)}
+}
+
+// sample returns a point (x, y) in the unit square with y selecting the row,
+// and the density of choosing it
+func (d *piecewise_2d) sample(u float64, v float64) (x float64, y float64, pdf float64) {
+
+	y, pdf_y, row := d.marginal.sample(v)
+	x, pdf_x, _ := d.rows[row].sample(u)
+
+	return x, y, pdf_x * pdf_y
+}
+
+// pdf is the density of sample returning (x, y)
+func (d *piecewise_2d) pdf(x float64, y float64) float64 {
+
+	row := clamp_index(y, len(d.rows))
+	column := clamp_index(x, len(d.rows[row].values))
+
+	return d.marginal.pdf(row) * d.rows[row].pdf(column)
+}
+
+// clamp_index finds the piece of n that x in [0, 1] falls in
+func clamp_index(x float64, n int) int {
+	return max(0, min(int(x*float64(n)), n-1))
+}
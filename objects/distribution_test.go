@@ -0,0 +1,61 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPiecewise_2dPdfIntegratesToOne(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+
+	width, height := 7, 5
+	random := make([]float64, width*height)
+	for i := range random {
+		random[i] = rng.Float64() * 10
+	}
+
+	// A dark row and a single bright texel, as in an environment map
+	sparse := make([]float64, width*height)
+	sparse[2*width+3] = 1000
+	sparse[width+1] = 0.5
+
+	tests := map[string][]float64{
+		"random": random,
+		"sparse": sparse,
+		"black":  make([]float64, width*height),
+	}
+
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := new_piecewise_2d(values, width, height)
+
+			// Midpoint rule, several points per piece
+			n := 8
+			sum := 0.0
+			for j := 0; j < height*n; j++ {
+				for i := 0; i < width*n; i++ {
+					x := (float64(i) + 0.5) / float64(width*n)
+					y := (float64(j) + 0.5) / float64(height*n)
+					sum += d.pdf(x, y)
+				}
+			}
+			integral := sum / float64(width*height*n*n)
+
+			if math.Abs(integral-1) > 1e-9 {
+				t.Errorf("pdf integrates to %v, want 1", integral)
+			}
+
+			for k := 0; k < 1000; k++ {
+				x, y, pdf := d.sample(rng.Float64(), rng.Float64())
+				if x < 0 || x >= 1 || y < 0 || y >= 1 {
+					t.Fatalf("sample (%v, %v) is outside the unit square", x, y)
+				}
+				if want := d.pdf(x, y); math.Abs(pdf-want) > 1e-9*want {
+					t.Fatalf("sample (%v, %v) has pdf %v, pdf gives %v", x, y, pdf, want)
+				}
+			}
+		})
+	}
+}
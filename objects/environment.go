@@ -0,0 +1,139 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Infinite_light is a light surrounding the whole scene, which gives the
+// radiance of rays that leave it
+type Infinite_light interface {
+	Light
+	Le(direction Vec3) Color
+}
+
+// Environment_light surrounds the scene with an equirectangular image, giving
+// the radiance of rays that leave it. The top row of the image is straight
+// up and the map is turned by rotation degrees around the y axis. Directions
+// are sampled in proportion to the brightness of the image, so small bright
+// features such as the sun are found by shadow rays instead of by chance.
+type Environment_light struct {
+	image        Hdr_image
+	Intensity    float64
+	sin_rotation float64
+	cos_rotation float64
+	distribution piecewise_2d
+}
+
+func NewEnvironment_light(image Hdr_image, intensity float64, rotation float64) Environment_light {
+
+	radians := Degrees_to_radians(rotation)
+
+	// Rows near the poles cover less solid angle
+	weights := make([]float64, image.Width*image.Height)
+	for y := 0; y < image.Height; y++ {
+		sin_theta := math.Sin(Pi * (float64(y) + 0.5) / float64(image.Height))
+		for x := 0; x < image.Width; x++ {
+			weights[y*image.Width+x] = luminance(image.At(x, y)) * sin_theta
+		}
+	}
+
+	return Environment_light{
+		image:        image,
+		Intensity:    intensity,
+		sin_rotation: math.Sin(radians),
+		cos_rotation: math.Cos(radians),
+		distribution: new_piecewise_2d(weights, image.Width, image.Height),
+	}
+}
+
+// Load_environment_light reads an .hdr file or an ordinary image
+func Load_environment_light(path string, intensity float64, rotation float64) (Environment_light, error) {
+
+	image, err := Load_hdr(path)
+	if err != nil {
+		return Environment_light{}, err
+	}
+
+	return NewEnvironment_light(image, intensity, rotation), nil
+}
+
+// Le is the radiance arriving from far away along direction, which points
+// out of the scene
+func (l *Environment_light) Le(direction Vec3) Color {
+	s, t := l.map_coordinates(direction)
+	return l.lookup(s, t)
+}
+
+func (l *Environment_light) Sample_li(p Point3) (Light_sample, bool) {
+
+	s, t, map_pdf := l.distribution.sample(rand.Float64(), rand.Float64())
+	if map_pdf == 0 {
+		return Light_sample{}, false
+	}
+
+	theta := t * Pi
+	phi := 2*Pi*s - Pi
+	sin_theta := math.Sin(theta)
+	if sin_theta == 0 {
+		return Light_sample{}, false
+	}
+
+	local := NewVec3(math.Cos(phi)*sin_theta, math.Cos(theta), -math.Sin(phi)*sin_theta)
+
+	// Turn from the map back into the world
+	direction := NewVec3(
+		l.cos_rotation*local.X()+l.sin_rotation*local.Z(),
+		local.Y(),
+		-l.sin_rotation*local.X()+l.cos_rotation*local.Z(),
+	)
+
+	return Light_sample{
+		Direction: direction,
+		Distance:  Infinity,
+		Radiance:  l.lookup(s, t),
+		Pdf:       map_pdf / (2 * Pi * Pi * sin_theta),
+	}, true
+}
+
+func (l *Environment_light) Pdf_li(p Point3, direction Vec3) float64 {
+
+	s, t := l.map_coordinates(direction)
+
+	sin_theta := math.Sin(t * Pi)
+	if sin_theta == 0 {
+		return 0
+	}
+
+	return l.distribution.pdf(s, t) / (2 * Pi * Pi * sin_theta)
+}
+
+// map_coordinates finds where direction lands on the map, with s across and
+// t down the image, both in [0, 1]
+func (l *Environment_light) map_coordinates(direction Vec3) (float64, float64) {
+
+	d := Unit_vector(direction)
+
+	x := l.cos_rotation*d.X() - l.sin_rotation*d.Z()
+	z := l.sin_rotation*d.X() + l.cos_rotation*d.Z()
+
+	s := (math.Atan2(-z, x) + Pi) / (2 * Pi)
+	t := math.Acos(math.Max(-1, math.Min(1, d.Y()))) / Pi
+
+	return s, t
+}
+
+// lookup returns the pixel under (s, t) without filtering, so the radiance is
+// constant over each piece of the sampling density
+func (l *Environment_light) lookup(s float64, t float64) Color {
+	x := clamp_index(s, l.image.Width)
+	y := clamp_index(t, l.image.Height)
+	return l.image.At(x, y).Mult(l.Intensity)
+}
+
+func luminance(c Color) float64 {
+	return 0.2126*c.X() + 0.7152*c.Y() + 0.0722*c.Z()
+}
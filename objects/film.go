@@ -0,0 +1,76 @@
+package objects
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"sync"
+
+	. "raytracer/common"
+)
+
+// Film accumulates radiance per pixel. Besides the samples traced through a
+// pixel it collects splats: contributions from light paths that land on an
+// arbitrary pixel, so adding them is guarded by a lock per row.
+type Film struct {
+	width, height int
+	pixels        []Color
+	splats        []Color
+	row_locks     []sync.Mutex
+}
+
+func NewFilm(width int, height int) Film {
+	return Film{
+		width:     width,
+		height:    height,
+		pixels:    make([]Color, width*height),
+		splats:    make([]Color, width*height),
+		row_locks: make([]sync.Mutex, height),
+	}
+}
+
+// Add records a sample for a pixel. Only the goroutine rendering that pixel
+// may call it.
+func (f *Film) Add(i int, j int, c Color) {
+	idx := j*f.width + i
+	f.pixels[idx] = f.pixels[idx].Add(c)
+}
+
+// Splat adds a contribution to any pixel, safe to call concurrently
+func (f *Film) Splat(i int, j int, c Color) {
+	f.row_locks[j].Lock()
+	idx := j*f.width + i
+	f.splats[idx] = f.splats[idx].Add(c)
+	f.row_locks[j].Unlock()
+}
+
+// Image resolves the film after samples_per_pixel samples of every pixel.
+// Splats estimate the whole image from each light path, so they are spread
+// over the number of pixels as well.
+func (f *Film) Image(samples_per_pixel int) *image.RGBA {
+
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	splat_scale := 1.0 / float64(f.width*f.height)
+
+	for j := 0; j < f.height; j++ {
+		for i := 0; i < f.width; i++ {
+			idx := j*f.width + i
+			pixel_color := f.pixels[idx].Add(f.splats[idx].Mult(splat_scale))
+			Write_color(pixel_color, samples_per_pixel, img, i, j)
+		}
+	}
+
+	return img
+}
+
+func write_png(img *image.RGBA, path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,184 @@
+package objects
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	. "raytracer/common"
+)
+
+// Hdr_image is an image of linear radiance values, row by row from the top
+type Hdr_image struct {
+	Width, Height int
+	Pixels        []Color
+}
+
+func (img *Hdr_image) At(x int, y int) Color {
+	return img.Pixels[y*img.Width+x]
+}
+
+// Load_hdr reads a Radiance .hdr file, or any other image the image package
+// can decode. Ordinary images are used as they are, the same way
+// Image_texture reads them, so a backdrop matches the textures in the scene.
+func Load_hdr(path string) (Hdr_image, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Hdr_image{}, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	if strings.HasSuffix(strings.ToLower(path), ".hdr") {
+		return Read_hdr(reader)
+	}
+
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return Hdr_image{}, err
+	}
+
+	return hdr_from_image(img), nil
+}
+
+func hdr_from_image(img image.Image) Hdr_image {
+
+	bounds := img.Bounds()
+	hdr := Hdr_image{Width: bounds.Dx(), Height: bounds.Dy(), Pixels: make([]Color, bounds.Dx()*bounds.Dy())}
+
+	for y := 0; y < hdr.Height; y++ {
+		for x := 0; x < hdr.Width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			hdr.Pixels[y*hdr.Width+x] = NewColor(float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+		}
+	}
+
+	return hdr
+}
+
+// Read_hdr decodes the Radiance RGBE format: a text header ending in a blank
+// line, a "-Y height +X width" resolution line and then the scanlines, each
+// either run length encoded per channel or stored as plain RGBE quadruples
+func Read_hdr(r *bufio.Reader) (Hdr_image, error) {
+
+	magic, err := r.ReadString('\n')
+	if err != nil {
+		return Hdr_image{}, err
+	}
+	if !strings.HasPrefix(magic, "#?") {
+		return Hdr_image{}, fmt.Errorf("hdr: not a Radiance file")
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return Hdr_image{}, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+			return Hdr_image{}, fmt.Errorf("hdr: unsupported format %s", format)
+		}
+	}
+
+	var width, height int
+	resolution, err := r.ReadString('\n')
+	if err != nil {
+		return Hdr_image{}, err
+	}
+	if _, err := fmt.Sscanf(resolution, "-Y %d +X %d", &height, &width); err != nil {
+		return Hdr_image{}, fmt.Errorf("hdr: unsupported resolution line %q", strings.TrimSpace(resolution))
+	}
+	if width <= 0 || height <= 0 || width*height > 1<<28 {
+		return Hdr_image{}, fmt.Errorf("hdr: bad size %d x %d", width, height)
+	}
+
+	img := Hdr_image{Width: width, Height: height, Pixels: make([]Color, width*height)}
+	scanline := make([]byte, 4*width)
+
+	for y := 0; y < height; y++ {
+		if err := read_hdr_scanline(r, scanline, width); err != nil {
+			return Hdr_image{}, err
+		}
+		for x := 0; x < width; x++ {
+			img.Pixels[y*width+x] = rgbe_to_color(scanline[4*x : 4*x+4])
+		}
+	}
+
+	return img, nil
+}
+
+// read_hdr_scanline fills scanline with width RGBE quadruples
+func read_hdr_scanline(r *bufio.Reader, scanline []byte, width int) error {
+
+	if _, err := io.ReadFull(r, scanline[:4]); err != nil {
+		return err
+	}
+
+	// Run length encoded scanlines start with 2, 2 and the width
+	encoded := width >= 8 && width < 0x8000 && scanline[0] == 2 && scanline[1] == 2 && int(scanline[2])<<8|int(scanline[3]) == width
+	if !encoded {
+		_, err := io.ReadFull(r, scanline[4:])
+		return err
+	}
+
+	// Each channel is stored separately as runs of one repeated byte (a count
+	// above 128) or of count literal bytes
+	for channel := 0; channel < 4; channel++ {
+		for x := 0; x < width; {
+			count, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			if count > 128 {
+				run := int(count) - 128
+				value, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+run > width {
+					return fmt.Errorf("hdr: bad scanline")
+				}
+				for ; run > 0; run-- {
+					scanline[4*x+channel] = value
+					x++
+				}
+			} else {
+				run := int(count)
+				if run == 0 || x+run > width {
+					return fmt.Errorf("hdr: bad scanline")
+				}
+				for ; run > 0; run-- {
+					value, err := r.ReadByte()
+					if err != nil {
+						return err
+					}
+					scanline[4*x+channel] = value
+					x++
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// rgbe_to_color expands three mantissas sharing one exponent
+func rgbe_to_color(rgbe []byte) Color {
+	if rgbe[3] == 0 {
+		return NewColor(0, 0, 0)
+	}
+	scale := math.Ldexp(1, int(rgbe[3])-(128+8))
+	return NewColor(float64(rgbe[0])*scale, float64(rgbe[1])*scale, float64(rgbe[2])*scale)
+}
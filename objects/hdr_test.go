@@ -0,0 +1,83 @@
+package objects
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	. "raytracer/common"
+)
+
+func TestRead_hdr(t *testing.T) {
+
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 2 +X 8\n"
+
+	var fixture []byte
+	fixture = append(fixture, header...)
+
+	// First scanline run length encoded, red, blue and exponent as runs and
+	// green as literals
+	fixture = append(fixture, 2, 2, 0, 8)
+	fixture = append(fixture, 128+8, 128)
+	fixture = append(fixture, 8, 0, 16, 32, 48, 64, 80, 96, 112)
+	fixture = append(fixture, 128+8, 32)
+	fixture = append(fixture, 128+8, 129)
+
+	// Second scanline as plain quadruples
+	for x := 0; x < 8; x++ {
+		fixture = append(fixture, 128, 128, 128, byte(128+x))
+	}
+
+	img, err := Read_hdr(bufio.NewReader(bytes.NewReader(fixture)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Width != 8 || img.Height != 2 {
+		t.Fatalf("size is %d x %d, want 8 x 2", img.Width, img.Height)
+	}
+
+	for x := 0; x < 8; x++ {
+		// Exponent 129 scales mantissas by 2^-7
+		want := NewColor(1, float64(16*x)/128, 0.25)
+		if got := img.At(x, 0); got != want {
+			t.Errorf("pixel (%d, 0) is %v, want %v", x, got, want)
+		}
+
+		// Mantissa 128 is one half, doubled by each step of the exponent
+		value := 0.5 * float64(int(1)<<x)
+		if got := img.At(x, 1); got != NewColor(value, value, value) {
+			t.Errorf("pixel (%d, 1) is %v, want %v", x, got, value)
+		}
+	}
+}
+
+func TestRead_hdrRejectsBadFiles(t *testing.T) {
+
+	tests := map[string]string{
+		"magic":      "P6\n",
+		"format":     "#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n",
+		"resolution": "#?RADIANCE\n\n+X 1 -Y 1\n",
+		"truncated":  "#?RADIANCE\n\n-Y 1 +X 2\n\x80\x80\x80\x80",
+		"long run":   "#?RADIANCE\n\n-Y 1 +X 8\n\x02\x02\x00\x08\x89\x80",
+	}
+
+	for name, data := range tests {
+		if _, err := Read_hdr(bufio.NewReader(bytes.NewReader([]byte(data)))); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestHdr_from_imageMatchesImage_texture(t *testing.T) {
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{255, 51, 0, 255})
+
+	hdr := hdr_from_image(img)
+	if got, want := hdr.At(0, 0), NewColor(1, 0.2, 0); got != want {
+		t.Errorf("pixel is %v, want %v", got, want)
+	}
+}
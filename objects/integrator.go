@@ -0,0 +1,20 @@
+package objects
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Integrator computes the light arriving at the camera along a ray. The
+// camera's render methods use the path tracer unless another is set.
+type Integrator interface {
+	Li(c *Camera, r *Ray, world Hittable) Color
+}
+
+// Path_tracer is the default integrator: unidirectional path tracing with
+// light sampling and Russian roulette
+type Path_tracer struct{}
+
+func (p *Path_tracer) Li(c *Camera, r *Ray, world Hittable) Color {
+	return trace(c, r, c.Max_depth, world)
+}
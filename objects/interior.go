@@ -0,0 +1,218 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// max_interior_steps bounds the scattering events of one walk through an
+// object's interior, since thick, bright media can scatter for a long time
+const max_interior_steps = 256
+
+// walk_interior follows a path through a medium from ray, whose closest
+// hit is rec, until it reaches a surface. It returns the ray arriving at that
+// surface, its hit record and the throughput gathered on the way, or false
+// if the path was absorbed or escaped.
+//
+// Distances are sampled from the extinction of a randomly chosen channel and
+// weighted by the average density over the channels, so colored media with
+// a different mean free path per channel stay unbiased.
+func walk_interior(world Hittable, ray Ray, rec Hit_record, medium Medium) (Ray, Hit_record, Color, bool) {
+
+	// Media that only absorb, like colored glass, attenuate the path
+	// exactly without sampling any distance
+	if medium.Albedo.Near_zero() {
+		return ray, rec, beer_lambert(medium.Sigma_t, rec.T*ray.Direction.Length()), true
+	}
+
+	throughput := NewColor(1, 1, 1)
+	sigma_t := medium.Sigma_t.XYZ()
+
+	for step := 0; step < max_interior_steps; step++ {
+
+		ray_length := ray.Direction.Length()
+		surface_distance := rec.T * ray_length
+
+		channel := rand.Intn(3)
+		distance := Infinity
+		if sigma_t[channel] > 0 {
+			distance = -math.Log(1-rand.Float64()) / sigma_t[channel]
+		}
+
+		if distance >= surface_distance {
+			// Reached the surface: weight by the chance of getting this far
+			transmittance := beer_lambert(medium.Sigma_t, surface_distance)
+			pdf := (transmittance.X() + transmittance.Y() + transmittance.Z()) / 3
+			return ray, rec, ComponentMultiply(throughput, transmittance).Div(pdf), true
+		}
+
+		transmittance := beer_lambert(medium.Sigma_t, distance)
+		density := ComponentMultiply(medium.Sigma_t, transmittance)
+		pdf := (density.X() + density.Y() + density.Z()) / 3
+
+		scattering := ComponentMultiply(medium.Albedo, density)
+		throughput = ComponentMultiply(throughput, scattering).Div(pdf)
+
+		if throughput.Near_zero() {
+			return ray, rec, throughput, false
+		}
+
+		// Scatter isotropically and find the next surface
+		ray = ray.With(ray.At(distance/ray_length), Random_unit_vector())
+		ray.Kind = Ray_diffuse
+		rec = Hit_record{}
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			return ray, rec, throughput, false
+		}
+	}
+
+	return ray, rec, throughput, false
+}
+
+// beer_lambert is the fraction of light passing distance through a medium
+func beer_lambert(sigma_t Color, distance float64) Color {
+	return NewColor(
+		math.Exp(-sigma_t.X()*distance),
+		math.Exp(-sigma_t.Y()*distance),
+		math.Exp(-sigma_t.Z()*distance),
+	)
+}
+
+// media lists the filled objects a path is inside, in the order it entered
+// them. Each is known by its material, so the object's primitives all bound
+// the same medium.
+type media []medium_entry
+
+type medium_entry struct {
+	material Material
+	medium   Medium
+	ior      float64
+	priority int
+}
+
+// new_medium_entry describes the medium of the filled material m, which
+// counts as air with the lowest priority unless it is Nested
+func new_medium_entry(m Material, filled Interior, rec *Hit_record) medium_entry {
+	entry := medium_entry{material: m, medium: filled.Interior(rec), ior: 1}
+	if nested, ok := m.(Nested); ok && nested.Medium_priority() >= 0 {
+		entry.ior = nested.Index_of_refraction()
+		entry.priority = nested.Medium_priority()
+	}
+	return entry
+}
+
+// current is the medium filling the space the path is in, the one of highest
+// priority and the latest entered among equals, or -1 outside every object
+func (ms media) current() int {
+	index := -1
+	for i := range ms {
+		if index < 0 || ms[i].priority >= ms[index].priority {
+			index = i
+		}
+	}
+	return index
+}
+
+// outside is the IOR beyond the surface of m seen from the path: the medium
+// it would enter on leaving m, or the one it is in when entering m
+func (ms media) outside(m Material) float64 {
+	index := -1
+	for i := range ms {
+		if ms[i].material != m && (index < 0 || ms[i].priority >= ms[index].priority) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return 1
+	}
+	return ms[index].ior
+}
+
+// beyond is the media on the side of the surface at rec that direction
+// points to: ms itself on the side the path arrived from, or the media after
+// passing through the surface of m
+func (ms media) beyond(m Material, rec *Hit_record, direction Vec3) media {
+	filled, ok := m.(Interior)
+	if !ok || Dot(direction, rec.Normal) > 0 {
+		return ms
+	}
+	return ms.cross(m, filled, rec)
+}
+
+// transmittance is the fraction of light crossing distance of the current
+// medium without scattering or being absorbed
+func (ms media) transmittance(distance float64) Color {
+	index := ms.current()
+	if index < 0 {
+		return NewColor(1, 1, 1)
+	}
+	return beer_lambert(ms[index].medium.Sigma_t, distance)
+}
+
+// ignores tells whether the surface of m lies inside a medium of higher
+// priority, so the path passes it unchanged
+func (ms media) ignores(m Material) bool {
+	nested, ok := m.(Nested)
+	if !ok || nested.Medium_priority() < 0 {
+		return false
+	}
+	index := ms.current()
+	return index >= 0 && ms[index].material != m && nested.Medium_priority() < ms[index].priority
+}
+
+// cross updates the media as the path passes through the surface of a
+// filled material
+func (ms media) cross(m Material, filled Interior, rec *Hit_record) media {
+	if rec.Front_face {
+		return append(ms, new_medium_entry(m, filled, rec))
+	}
+	for i := len(ms) - 1; i >= 0; i-- {
+		if ms[i].material == m {
+			return append(ms[:i:i], ms[i+1:]...)
+		}
+	}
+	return ms
+}
+
+// cross_media follows ray from its closest hit rec through the media it is
+// in, passing through the ignored surfaces of nested dielectrics, and returns
+// the ray arriving at the next real surface with the throughput on the way.
+// That surface's hit record is told the IOR beyond it.
+func cross_media(world Hittable, ray Ray, rec Hit_record, inside media) (Ray, Hit_record, media, Color, bool) {
+
+	throughput := NewColor(1, 1, 1)
+
+	for step := 0; step < max_interior_steps; step++ {
+
+		if index := inside.current(); index >= 0 {
+			var weight Color
+			var ok bool
+			ray, rec, weight, ok = walk_interior(world, ray, rec, inside[index].medium)
+			if !ok {
+				return ray, rec, inside, throughput, false
+			}
+			throughput = ComponentMultiply(throughput, weight)
+		}
+
+		m := *rec.Mat
+		filled, is_filled := m.(Interior)
+
+		if !is_filled || !inside.ignores(m) {
+			rec.Outside_ior = inside.outside(m)
+			return ray, rec, inside, throughput, true
+		}
+
+		inside = inside.cross(m, filled, &rec)
+
+		ray = ray.With(rec.P, ray.Direction)
+		rec = Hit_record{}
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			return ray, rec, inside, throughput, false
+		}
+	}
+
+	return ray, rec, inside, throughput, false
+}
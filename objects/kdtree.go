@@ -0,0 +1,142 @@
+package objects
+
+import (
+	. "raytracer/common"
+)
+
+// Kd_tree indexes a set of points for fixed radius searches. It is stored
+// implicitly as a balanced tree: the node of every range sits at its middle,
+// with the points on either side split along the widest axis of the range.
+type Kd_tree struct {
+	points []Point3
+	order  []int // point index stored at each node
+	axes   []int
+}
+
+func NewKd_tree(points []Point3) Kd_tree {
+
+	tree := Kd_tree{
+		points: points,
+		order:  make([]int, len(points)),
+		axes:   make([]int, len(points)),
+	}
+
+	for i := range tree.order {
+		tree.order[i] = i
+	}
+
+	tree.build(0, len(points))
+
+	return tree
+}
+
+func (t *Kd_tree) build(lo int, hi int) {
+
+	if hi-lo <= 1 {
+		return
+	}
+
+	// Split along the axis with the largest extent
+	min := t.points[t.order[lo]].XYZ()
+	max := min
+	for k := lo + 1; k < hi; k++ {
+		p := t.points[t.order[k]].XYZ()
+		for a := 0; a < 3; a++ {
+			if p[a] < min[a] {
+				min[a] = p[a]
+			}
+			if p[a] > max[a] {
+				max[a] = p[a]
+			}
+		}
+	}
+
+	axis := 0
+	for a := 1; a < 3; a++ {
+		if max[a]-min[a] > max[axis]-min[axis] {
+			axis = a
+		}
+	}
+
+	mid := (lo + hi) / 2
+	t.select_nth(lo, hi, mid, axis)
+	t.axes[mid] = axis
+
+	t.build(lo, mid)
+	t.build(mid+1, hi)
+}
+
+// select_nth partially sorts order[lo:hi] so the element at nth is the one
+// that would be there if the range were sorted along axis
+func (t *Kd_tree) select_nth(lo int, hi int, nth int, axis int) {
+
+	coord := func(k int) float64 {
+		p := t.points[t.order[k]].XYZ()
+		return p[axis]
+	}
+
+	hi--
+	for lo < hi {
+		pivot := coord((lo + hi) / 2)
+		i, j := lo, hi
+		for i <= j {
+			for coord(i) < pivot {
+				i++
+			}
+			for coord(j) > pivot {
+				j--
+			}
+			if i <= j {
+				t.order[i], t.order[j] = t.order[j], t.order[i]
+				i++
+				j--
+			}
+		}
+		if nth <= j {
+			hi = j
+		} else if nth >= i {
+			lo = i
+		} else {
+			return
+		}
+	}
+}
+
+// Within calls found with the index of every point closer than radius to p
+func (t *Kd_tree) Within(p Point3, radius float64, found func(index int)) {
+	t.within(0, len(t.order), p.XYZ(), radius*radius, found)
+}
+
+func (t *Kd_tree) within(lo int, hi int, p [3]float64, radius_squared float64, found func(index int)) {
+
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	node := t.points[t.order[mid]]
+
+	if node.Sub(NewPoint3(p[0], p[1], p[2])).Length_squared() < radius_squared {
+		found(t.order[mid])
+	}
+
+	if hi-lo == 1 {
+		return
+	}
+
+	axis := t.axes[mid]
+	d := p[axis] - node.XYZ()[axis]
+
+	// Search the side containing p first, the other only if the sphere crosses the plane
+	if d < 0 {
+		t.within(lo, mid, p, radius_squared, found)
+		if d*d < radius_squared {
+			t.within(mid+1, hi, p, radius_squared, found)
+		}
+	} else {
+		t.within(mid+1, hi, p, radius_squared, found)
+		if d*d < radius_squared {
+			t.within(lo, mid, p, radius_squared, found)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package objects
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "raytracer/common"
+)
+
+// brute_force_within is the reference Kd_tree.Within is checked against
+func brute_force_within(points []Point3, p Point3, radius float64) []int {
+	var found []int
+	for i, q := range points {
+		if q.Sub(p).Length_squared() < radius*radius {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+func TestKd_treeWithinMatchesBruteForce(t *testing.T) {
+
+	rng := rand.New(rand.NewSource(1))
+	random_point := func() Point3 {
+		return NewPoint3(rng.Float64()*10-5, rng.Float64()*10-5, rng.Float64()*2)
+	}
+
+	tests := map[string][]Point3{
+		"empty":  nil,
+		"single": {NewPoint3(1, 2, 3)},
+	}
+
+	scattered := make([]Point3, 2000)
+	for i := range scattered {
+		scattered[i] = random_point()
+	}
+	tests["scattered"] = scattered
+
+	// Photons pile up on the same spots and lie on flat surfaces
+	stacked := make([]Point3, 500)
+	for i := range stacked {
+		stacked[i] = NewPoint3(float64(i%7), 0, float64(i%3))
+	}
+	tests["stacked"] = stacked
+
+	for name, points := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := NewKd_tree(points)
+
+			for q := 0; q < 200; q++ {
+				p := random_point()
+				radius := rng.Float64() * 3
+
+				var got []int
+				tree.Within(p, radius, func(index int) {
+					got = append(got, index)
+				})
+				sort.Ints(got)
+
+				want := brute_force_within(points, p, radius)
+				if len(got) != len(want) {
+					t.Fatalf("query %v radius %.3f found %d points, want %d", p, radius, len(got), len(want))
+				}
+				for k := range want {
+					if got[k] != want[k] {
+						t.Fatalf("query %v radius %.3f found %v, want %v", p, radius, got, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Sampleable is a shape that can generate directions towards itself, along
+// with the solid angle density of doing so, and points on its surface
+type Sampleable interface {
+	Hittable
+	Pdf_value(origin Point3, direction Vec3) float64
+	Random(origin Point3) Vec3
+	Area() float64
+	Sample_surface() Hit_record
+}
+
+// Emitter is a light that can also start paths of its own, for the
+// integrators that trace from the lights: bidirectional path tracing and
+// photon mapping. Sample_le returns the ray leaving the light with a record
+// of its origin, whose normal gives the cosine the emitted light is scaled
+// by. Pdf_le gives the densities of Sample_le for a ray leaving a point with
+// the given normal.
+type Emitter interface {
+	Light
+	Sample_le() (ray Ray, rec Hit_record, le Color, pdf_pos float64, pdf_dir float64)
+	Pdf_le(normal Vec3, direction Vec3) (pdf_pos float64, pdf_dir float64)
+}
+
+// Area_light turns an emissive shape into a light that can be sampled directly
+type Area_light struct {
+	Shape Sampleable
+}
+
+func NewArea_light(shape Sampleable) Area_light {
+	return Area_light{Shape: shape}
+}
+
+func (l *Area_light) Sample_li(p Point3) (Light_sample, bool) {
+
+	direction := Unit_vector(l.Shape.Random(p))
+
+	var rec Hit_record
+	r := NewRay(p, direction)
+	if !l.Shape.Hit(&r, NewInterval(0.001, Infinity), &rec) {
+		return Light_sample{}, false
+	}
+
+	pdf := l.Shape.Pdf_value(p, direction)
+	if pdf <= 0 {
+		return Light_sample{}, false
+	}
+
+	m := *rec.Mat
+
+	return Light_sample{
+		Direction: direction,
+		Distance:  rec.T,
+		Radiance:  m.Emitted(rec.U, rec.V, &rec.P),
+		Pdf:       pdf,
+	}, true
+}
+
+func (l *Area_light) Pdf_li(p Point3, direction Vec3) float64 {
+	return l.Shape.Pdf_value(p, direction)
+}
+
+// Sample_le picks a ray leaving the light, for tracing paths from the light
+// towards the camera. Both sides of the shape emit, so a side is chosen at
+// random and the direction is cosine weighted around it. The returned record
+// holds the point, outward normal and surface coordinates of the origin.
+func (l *Area_light) Sample_le() (ray Ray, rec Hit_record, le Color, pdf_pos float64, pdf_dir float64) {
+
+	rec = l.Shape.Sample_surface()
+
+	side := rec.Normal
+	if rand.Float64() < 0.5 {
+		side = side.Mult(-1)
+	}
+
+	uvw := NewOnb(side)
+	direction := uvw.Local(Random_cosine_direction())
+
+	m := *rec.Mat
+	le = m.Emitted(rec.U, rec.V, &rec.P)
+
+	pdf_pos, pdf_dir = l.Pdf_le(rec.Normal, direction)
+
+	return NewRay(rec.P, direction), rec, le, pdf_pos, pdf_dir
+}
+
+// Pdf_le gives the area density of Sample_le picking a point and the solid
+// angle density of it picking direction from a point with the given normal
+func (l *Area_light) Pdf_le(normal Vec3, direction Vec3) (pdf_pos float64, pdf_dir float64) {
+	cosine := math.Abs(Dot(normal, Unit_vector(direction)))
+	return 1 / l.Shape.Area(), cosine / (2 * Pi)
+}
+
+// Find_lights walks the scene and wraps every emissive Quad and Sphere in an
+// Area_light, and collects the delta lights added to it. Objects behind
+// transforms are not collected, since their shapes cannot be sampled in world
+// space.
+func Find_lights(world Hittable) []Light {
+
+	var lights []Light
+	seen := make(map[Hittable]bool)
+
+	var visit func(h Hittable)
+	visit = func(h Hittable) {
+		var shape Sampleable
+
+		switch obj := h.(type) {
+		case *Sphere:
+			if is_emissive(obj.Mat) {
+				shape = obj
+			}
+		case *Quad:
+			if is_emissive(obj.mat) {
+				shape = obj
+			}
+		case Aggregate:
+			for _, child := range obj.Children() {
+				visit(child)
+			}
+		case Light:
+			if !seen[h] {
+				seen[h] = true
+				lights = append(lights, obj)
+			}
+		}
+
+		// BVH leaves may reference the same object twice
+		if shape != nil && !seen[shape] {
+			seen[shape] = true
+			light := NewArea_light(shape)
+			lights = append(lights, &light)
+		}
+	}
+
+	visit(world)
+
+	return lights
+}
+
+func is_emissive(m Material) bool {
+	switch mat := m.(type) {
+	case *Diffuse_light:
+		return true
+	case *Mix:
+		return is_emissive(mat.A) || is_emissive(mat.B)
+	}
+	return false
+}
+
+// scene_bounds is the box around every bounded object in world. Objects
+// behind transforms report unbounded boxes and are left out.
+func scene_bounds(world Hittable) Aabb {
+
+	bounds := NewAabb(Empty, Empty, Empty)
+
+	var visit func(h Hittable)
+	visit = func(h Hittable) {
+		box := h.Bounding_box()
+
+		bounded := true
+		for axis := 0; axis < 3; axis++ {
+			interval := box.Axis_interval(axis)
+			if math.IsInf(interval.Min, -1) || math.IsInf(interval.Max, 1) {
+				bounded = false
+			}
+		}
+
+		if bounded {
+			bounds = Merge(bounds, box)
+		} else if aggregate, ok := h.(Aggregate); ok {
+			for _, child := range aggregate.Children() {
+				visit(child)
+			}
+		}
+	}
+
+	visit(world)
+
+	return bounds
+}
@@ -0,0 +1,98 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Constant_medium fills a closed, convex boundary with a participating medium
+// of uniform density, such as smoke or fog. A ray passing through scatters at
+// a random distance drawn from the exponential free flight distribution, or
+// passes straight through when that distance lies beyond the far side.
+type Constant_medium struct {
+	boundary        Hittable
+	neg_inv_density float64
+	phase_function  Material
+}
+
+func NewConstant_medium(boundary Hittable, density float64, albedo Color) Constant_medium {
+	phase := NewIsotropic(albedo)
+	return NewConstant_mediumPhase(boundary, density, &phase)
+}
+
+// NewConstant_mediumPhase uses a given phase function, e.g. Henyey_greenstein
+func NewConstant_mediumPhase(boundary Hittable, density float64, phase Material) Constant_medium {
+	return Constant_medium{boundary: boundary, neg_inv_density: -1 / density, phase_function: phase}
+}
+
+// NewFog is a global fog: a medium filling a sphere of the given radius
+// around center, large enough to hold the scene and camera. Rays leaving the
+// sphere see the background.
+func NewFog(center Point3, radius float64, density float64, phase Material) Constant_medium {
+	boundary := NewSphere(center, radius, nil)
+	return NewConstant_mediumPhase(&boundary, density, phase)
+}
+
+func (m *Constant_medium) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+
+	t_enter, t_exit, ok := m.span(r, ray_t)
+	if !ok {
+		return false
+	}
+
+	ray_length := r.Direction.Length()
+	distance_inside_boundary := (t_exit - t_enter) * ray_length
+	hit_distance := m.neg_inv_density * math.Log(rand.Float64())
+
+	if hit_distance > distance_inside_boundary {
+		return false
+	}
+
+	rec.T = t_enter + hit_distance/ray_length
+	rec.P = r.At(rec.T)
+
+	// A point in a volume has no surface, so the normal is arbitrary
+	rec.Normal = NewVec3(1, 0, 0)
+	rec.Front_face = true
+	rec.Mat = &m.phase_function
+	rec.Object = m
+
+	return true
+}
+
+// Occluded picks whether the ray would scatter before leaving the medium,
+// which blocks it with probability one minus the transmittance
+func (m *Constant_medium) Occluded(r *Ray, ray_t Interval) bool {
+	var rec Hit_record
+	return m.Hit(r, ray_t, &rec)
+}
+
+// span finds the part of ray_t that lies inside the boundary
+func (m *Constant_medium) span(r *Ray, ray_t Interval) (float64, float64, bool) {
+
+	var rec1, rec2 Hit_record
+
+	if !m.boundary.Hit(r, Universe, &rec1) {
+		return 0, 0, false
+	}
+
+	if !m.boundary.Hit(r, NewInterval(rec1.T+0.0001, Infinity), &rec2) {
+		return 0, 0, false
+	}
+
+	t_enter := math.Max(rec1.T, ray_t.Min)
+	t_exit := math.Min(rec2.T, ray_t.Max)
+
+	if t_enter >= t_exit {
+		return 0, 0, false
+	}
+
+	return math.Max(t_enter, 0), t_exit, true
+}
+
+func (m *Constant_medium) Bounding_box() Aabb {
+	return m.boundary.Bounding_box()
+}
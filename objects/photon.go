@@ -0,0 +1,242 @@
+package objects
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"time"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Photon mapping. Light paths through glass and mirrors onto diffuse
+// surfaces (caustics) are almost never found by tracing from the camera, as
+// the path would have to hit the light after a specular bounce. Instead
+// photons are shot from the lights, stored where they land on a diffuse
+// surface after at least one specular bounce, and gathered from camera paths
+// as a density estimate within a fixed radius. Everything else is path traced
+// as usual.
+
+// Photon is the flux carried to a point, arriving from Direction
+type Photon struct {
+	P         Point3
+	Direction Vec3 // towards where the photon came from
+	Power     Color
+}
+
+// Photon_map holds photons for radius searches
+type Photon_map struct {
+	Photons []Photon
+	tree    Kd_tree
+}
+
+func NewPhoton_map(photons []Photon) Photon_map {
+
+	points := make([]Point3, len(photons))
+	for i, photon := range photons {
+		points[i] = photon.P
+	}
+
+	return Photon_map{Photons: photons, tree: NewKd_tree(points)}
+}
+
+// Radiance estimates the light reflected along r_in from the photons within
+// radius of the hit
+func (pm *Photon_map) Radiance(r_in *Ray, rec *Hit_record, m Material, radius float64) Color {
+
+	flux := NewColor(0, 0, 0)
+
+	pm.tree.Within(rec.P, radius, func(index int) {
+		photon := &pm.Photons[index]
+		flux = flux.Add(ComponentMultiply(bsdf_value(r_in, rec, m, photon.Direction), photon.Power))
+	})
+
+	return flux.Div(Pi * radius * radius)
+}
+
+// bsdf_value is the BSDF without the cosine that Eval includes; photon flux
+// already accounts for the angle it arrived at
+func bsdf_value(r_in *Ray, rec *Hit_record, m Material, direction Vec3) Color {
+	if m.Flags()&Bsdf_volume != 0 {
+		return m.Eval(r_in, rec, direction)
+	}
+
+	cosine := math.Abs(Dot(rec.Normal, Unit_vector(direction)))
+	if cosine < 1e-6 {
+		return NewColor(0, 0, 0)
+	}
+	return m.Eval(r_in, rec, direction).Div(cosine)
+}
+
+// emit_photon starts a photon from a random light. Its power is the flux of
+// the whole scene's lights divided by the number of photons shot.
+func (c *Camera) emit_photon(n_photons int) (Ray, Color, Emitter, bool) {
+
+	light := c.emitters[rand.Intn(len(c.emitters))]
+	pick_pdf := 1 / float64(len(c.emitters))
+
+	ray, rec, le, pdf_pos, pdf_dir := light.Sample_le()
+	if pdf_pos == 0 || pdf_dir == 0 || le.Near_zero() {
+		return Ray{}, Color{}, nil, false
+	}
+
+	cosine := math.Abs(Dot(rec.Normal, Unit_vector(ray.Direction)))
+	power := le.Mult(cosine / (pick_pdf * pdf_pos * pdf_dir * float64(n_photons)))
+
+	return ray, power, light, true
+}
+
+// trace_caustic_photon follows a photon from light through specular bounces
+// and returns where it lands on the first other surface. Photons that reach a
+// diffuse surface directly are direct light, which the path tracer already
+// handles, and those whose first surface is not linked to the light (see
+// Visibility) are dropped.
+func (c *Camera) trace_caustic_photon(world Hittable, ray Ray, power Color, light Emitter) (Photon, bool) {
+
+	// Filled objects the photon is inside
+	var inside media
+
+	for bounce := 0; bounce < c.Max_depth; bounce++ {
+		var rec Hit_record
+
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			return Photon{}, false
+		}
+
+		if bounce == 0 && !lights_hit(rec.Lit_by(), light) {
+			return Photon{}, false
+		}
+
+		if len(inside) > 0 {
+			var weight Color
+			var ok bool
+			ray, rec, inside, weight, ok = cross_media(world, ray, rec, inside)
+			if !ok {
+				return Photon{}, false
+			}
+			power = ComponentMultiply(power, weight)
+		}
+
+		m := *rec.Mat
+
+		if !m.Flags().Is_delta() {
+			if bounce == 0 {
+				return Photon{}, false
+			}
+			return Photon{P: rec.P, Direction: Unit_vector(ray.Direction.Mult(-1)), Power: power}, true
+		}
+
+		bs, ok := m.Sample(&ray, &rec)
+		if !ok {
+			return Photon{}, false
+		}
+
+		power = ComponentMultiply(power, bs.Weight)
+
+		if filled, ok := m.(Interior); ok && bs.Flags&Bsdf_transmission != 0 {
+			inside = inside.cross(m, filled, &rec)
+		}
+
+		ray = scattered_ray(&rec, bs)
+	}
+
+	return Photon{}, false
+}
+
+// Shoot_caustic_photons emits n_photons from the scene's lights and keeps
+// those that land on a diffuse surface after a specular bounce
+func (c *Camera) Shoot_caustic_photons(world Hittable, n_photons int) Photon_map {
+
+	if len(c.emitters) == 0 {
+		return NewPhoton_map(nil)
+	}
+
+	n_processes := 24
+	results := make(chan []Photon)
+
+	for p := 0; p < n_processes; p++ {
+		go func(count int) {
+			var photons []Photon
+			for k := 0; k < count; k++ {
+				ray, power, light, ok := c.emit_photon(n_photons)
+				if !ok {
+					continue
+				}
+				if photon, ok := c.trace_caustic_photon(world, ray, power, light); ok {
+					photons = append(photons, photon)
+				}
+			}
+			results <- photons
+		}((p+1)*n_photons/n_processes - p*n_photons/n_processes)
+	}
+
+	var photons []Photon
+	for p := 0; p < n_processes; p++ {
+		photons = append(photons, <-results...)
+	}
+
+	return NewPhoton_map(photons)
+}
+
+// caustic_estimate gathers caustics from photons within radius of a hit
+type caustic_estimate struct {
+	photons *Photon_map
+	radius  float64
+}
+
+// trace_with_caustics is trace with caustics taken from the photon map. Paths
+// that reach a light through specular bounces after a diffuse one would find
+// the same light the photons carry, so their emission is skipped.
+func trace_with_caustics(c *Camera, r *Ray, depth int, world Hittable, caustics *Photon_map, radius float64) Color {
+	path := rgb_path{radiance: NewColor(0, 0, 0), throughput: NewColor(1, 1, 1)}
+	follow_path(c, r, depth, world, &path, &caustic_estimate{photons: caustics, radius: radius})
+	return path.radiance
+}
+
+// RenderPhotonMap path traces the scene with caustics gathered from
+// n_photons photons within radius of each diffuse hit, and saves the result
+// to output.png. A larger radius trades noise for blur.
+func (c *Camera) RenderPhotonMap(world Hittable, n_photons int, radius float64) {
+
+	start := time.Now()
+
+	c.initialize()
+	c.Collect_lights(world)
+
+	caustics := c.Shoot_caustic_photons(world, n_photons)
+	if c.Log_scanlines {
+		fmt.Println("Stored", len(caustics.Photons), "caustic photons in", time.Since(start))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, c.Image_width, c.image_height))
+
+	done := make(chan bool)
+	n_processes := 24
+
+	for p := 0; p < n_processes; p++ {
+		go func(start int, end int) {
+			for j := start; j < end; j++ {
+				for i := 0; i < c.Image_width; i++ {
+					pixel_color := NewColor(0, 0, 0)
+					for sample := 0; sample < c.Sample_per_pixel; sample++ {
+						r := c.get_ray(i, j)
+						pixel_color = pixel_color.Add(trace_with_caustics(c, &r, c.Max_depth, world, &caustics, radius))
+					}
+					Write_color(pixel_color, c.Sample_per_pixel, img, i, j)
+				}
+			}
+			done <- true
+		}(p*c.image_height/n_processes, (p+1)*c.image_height/n_processes)
+	}
+
+	for p := 0; p < n_processes; p++ {
+		<-done
+	}
+
+	write_png(img, "output.png")
+
+	elapsed := time.Since(start)
+	fmt.Print("~~~~~~~~~~~~~~~~~~~~~~~~~~\nElapsed Time: ", elapsed, "\n~~~~~~~~~~~~~~~~~~~~~~~~~~\n")
+}
@@ -0,0 +1,97 @@
+package objects
+
+import (
+	"math"
+	"testing"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// mixed_mirror_box is a small Cornell box lit from the ceiling around a
+// sphere that mixes a diffuse material with a mirror
+func mixed_mirror_box() (Camera, Hittable_list) {
+
+	white := NewLambertian(NewColor(0.73, 0.73, 0.73))
+	red := NewLambertian(NewColor(0.65, 0.05, 0.05))
+	green := NewLambertian(NewColor(0.12, 0.45, 0.15))
+	light := NewDiffuse_light(NewColor(4, 4, 4))
+
+	diffuse := NewLambertian(NewColor(0.5, 0.5, 0.5))
+	mirror := NewMetal(NewColor(0.9, 0.9, 0.9), 0)
+	mix := NewMix(&diffuse, &mirror, 0.8)
+
+	var world Hittable_list
+
+	floor := NewQuad(NewPoint3(0, 0, 0), NewVec3(2, 0, 0), NewVec3(0, 0, 2), &white)
+	ceiling := NewQuad(NewPoint3(0, 2, 0), NewVec3(2, 0, 0), NewVec3(0, 0, 2), &white)
+	back := NewQuad(NewPoint3(0, 0, 0), NewVec3(2, 0, 0), NewVec3(0, 2, 0), &white)
+	left := NewQuad(NewPoint3(0, 0, 0), NewVec3(0, 0, 2), NewVec3(0, 2, 0), &red)
+	right := NewQuad(NewPoint3(2, 0, 0), NewVec3(0, 0, 2), NewVec3(0, 2, 0), &green)
+	lamp := NewQuad(NewPoint3(0.5, 1.999, 0.5), NewVec3(1, 0, 0), NewVec3(0, 0, 1), &light)
+	sphere := NewSphere(NewPoint3(1, 0.5, 0.9), 0.5, &mix)
+
+	world.Add(&floor)
+	world.Add(&ceiling)
+	world.Add(&back)
+	world.Add(&left)
+	world.Add(&right)
+	world.Add(&lamp)
+	world.Add(&sphere)
+
+	c := NewCamera()
+	c.Aspect_ratio = 1
+	c.Image_width = 16
+	c.Vfov = 40
+	c.Look_from = NewPoint3(1, 1, 5)
+	c.Look_at = NewPoint3(1, 1, 0)
+	c.Focus_dist = 4
+	c.Max_depth = 8
+	c.Background = NewColor(0, 0, 0)
+	c.initialize()
+	c.Collect_lights(&world)
+
+	return c, world
+}
+
+// mean_luminance averages the luminance of trace over samples rays per pixel
+func mean_luminance(c *Camera, samples int, trace func(r *Ray) Color) float64 {
+	total := 0.0
+	for j := 0; j < c.image_height; j++ {
+		for i := 0; i < c.Image_width; i++ {
+			for k := 0; k < samples; k++ {
+				r := c.get_ray(i, j)
+				color := trace(&r)
+				total += 0.2126*color.X() + 0.7152*color.Y() + 0.0722*color.Z()
+			}
+		}
+	}
+	return total / float64(c.Image_width*c.image_height*samples)
+}
+
+// A Mix with a mirror is not delta, so no caustic photons land through it.
+// The light its mirror lobe carries onto other surfaces must then still be
+// found by the path tracer when photons gather the caustics.
+func TestPhoton_mapMatchesPath_tracerOnMixedMirror(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("renders a scene")
+	}
+
+	c, world := mixed_mirror_box()
+	photons := c.Shoot_caustic_photons(&world, 20000)
+
+	const samples = 256
+	radius := 0.05
+
+	path_traced := mean_luminance(&c, samples, func(r *Ray) Color {
+		return trace(&c, r, c.Max_depth, &world)
+	})
+	photon_mapped := mean_luminance(&c, samples, func(r *Ray) Color {
+		return trace_with_caustics(&c, r, c.Max_depth, &world, &photons, radius)
+	})
+
+	if math.Abs(photon_mapped-path_traced) > 0.03*path_traced {
+		t.Errorf("photon mapping gives a mean luminance of %.4f, path tracing %.4f", photon_mapped, path_traced)
+	}
+}
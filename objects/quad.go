@@ -2,6 +2,7 @@ package objects
 
 import (
 	"math"
+	"math/rand"
 	. "raytracer/common"
 	. "raytracer/material"
 )
@@ -36,38 +37,52 @@ func (quad *Quad) setBbox() {
 
 func (quad *Quad) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 
-	denom := Dot(quad.normal, r.Direction)
+	t, alpha, beta, ok := quad.plane_coordinates(r, ray_t)
+	if !ok || !is_interior(alpha, beta, rec) {
+		return false
+	}
 
-	// fmt.Println(quad.Bbox)
+	rec.T = t
+	rec.P = r.At(t)
+	rec.Mat = &quad.mat
+	rec.Object = quad
+	rec.Set_face_normal(r, quad.normal)
 
-	if math.Abs(denom) < 1e-8 {
+	return true
+}
+
+func (quad *Quad) Occluded(r *Ray, ray_t Interval) bool {
+	_, alpha, beta, ok := quad.plane_coordinates(r, ray_t)
+	if !ok {
 		return false
 	}
 
+	var rec Hit_record
+	return is_interior(alpha, beta, &rec)
+}
+
+// plane_coordinates finds where the ray crosses the plane of the quad within
+// ray_t, returning the ray parameter and the crossing in multiples of the
+// edges u and v from the corner
+func (quad *Quad) plane_coordinates(r *Ray, ray_t Interval) (float64, float64, float64, bool) {
+	r.Count_primitive()
+
+	denom := Dot(quad.normal, r.Direction)
+	if math.Abs(denom) < 1e-8 {
+		return 0, 0, 0, false
+	}
+
 	t := (quad.d - Dot(quad.normal, r.Origin)) / denom
 	if !ray_t.Contains(t) {
-		// fmt.Printf("no ")
-		return false
+		return 0, 0, 0, false
 	}
 
-	intersection := r.At(t)
-	planar_hitpt_vector := intersection.Sub(quad.q)
+	planar_hitpt_vector := r.At(t).Sub(quad.q)
 
 	alpha := Dot(quad.w, Cross(planar_hitpt_vector, quad.v))
 	beta := Dot(quad.w, Cross(quad.u, planar_hitpt_vector))
 
-	if !is_interior(alpha, beta, rec) {
-
-		return false
-	}
-
-	rec.T = t
-	rec.P = intersection
-	rec.Mat = &quad.mat
-	rec.Set_face_normal(r, quad.normal)
-
-	// fmt.Print("!!!!")
-	return true
+	return t, alpha, beta, true
 }
 
 func is_interior(a float64, b float64, rec *Hit_record) bool {
@@ -88,6 +103,46 @@ func (quad *Quad) Bounding_box() Aabb {
 	return quad.Bbox
 }
 
+func (quad *Quad) Area() float64 {
+	return Cross(quad.u, quad.v).Length()
+}
+
+// Sample_surface picks a point uniformly over the quad
+func (quad *Quad) Sample_surface() Hit_record {
+	var rec Hit_record
+
+	rec.U = rand.Float64()
+	rec.V = rand.Float64()
+	rec.P = quad.q.Add(quad.u.Mult(rec.U)).Add(quad.v.Mult(rec.V))
+	rec.Normal = quad.normal
+	rec.Front_face = true
+	rec.Mat = &quad.mat
+	rec.Object = quad
+
+	return rec
+}
+
+// Pdf_value is the solid angle density of Random(origin) generating direction
+func (quad *Quad) Pdf_value(origin Point3, direction Vec3) float64 {
+	var rec Hit_record
+	r := NewRay(origin, direction)
+	if !quad.Hit(&r, NewInterval(0.001, Infinity), &rec) {
+		return 0
+	}
+
+	area := quad.Area()
+	distance_squared := rec.T * rec.T * direction.Length_squared()
+	cosine := math.Abs(Dot(direction, quad.normal) / direction.Length())
+
+	return distance_squared / (cosine * area)
+}
+
+// Random returns the direction from origin to a uniformly chosen point on the quad
+func (quad *Quad) Random(origin Point3) Vec3 {
+	p := quad.q.Add(quad.u.Mult(rand.Float64())).Add(quad.v.Mult(rand.Float64()))
+	return p.Sub(origin)
+}
+
 func NewBox(a Point3, b Point3, mat Material) Hittable_list {
 
 	var sides Hittable_list
@@ -125,41 +180,32 @@ func NewTri(q Point3, u Vec3, v Vec3, material Material) Tri {
 }
 
 func (tri *Tri) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
-	denom := Dot(tri.q.normal, r.Direction)
-
-	// fmt.Println(tri.q.Bbox)
-
-	if math.Abs(denom) < 1e-8 {
-		return false
-	}
-
-	t := (tri.q.d - Dot(tri.q.normal, r.Origin)) / denom
-	if !ray_t.Contains(t) {
-		// fmt.Printf("no ")
-		return false
-	}
-
-	intersection := r.At(t)
-	planar_hitpt_vector := intersection.Sub(tri.q.q)
-
-	alpha := Dot(tri.q.w, Cross(planar_hitpt_vector, tri.q.v))
-	beta := Dot(tri.q.w, Cross(tri.q.u, planar_hitpt_vector))
-
-	if !is_interiorTri(alpha, beta, rec) {
 
+	t, alpha, beta, ok := tri.q.plane_coordinates(r, ray_t)
+	if !ok || !is_interiorTri(alpha, beta, rec) {
 		return false
 	}
 
 	rec.T = t
-	rec.P = intersection
+	rec.P = r.At(t)
 	rec.Mat = &tri.q.mat
+	rec.Object = tri
 	rec.Set_face_normal(r, tri.q.normal)
 
-	// fmt.Print("!!!!")
 	return true
 
 }
 
+func (tri *Tri) Occluded(r *Ray, ray_t Interval) bool {
+	_, alpha, beta, ok := tri.q.plane_coordinates(r, ray_t)
+	if !ok {
+		return false
+	}
+
+	var rec Hit_record
+	return is_interiorTri(alpha, beta, &rec)
+}
+
 func is_interiorTri(a float64, b float64, rec *Hit_record) bool {
 
 	if a < 0 || b < 0 || a+b > 1 {
@@ -0,0 +1,219 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+const (
+	sky_scale      = 0.03 // radiance of a sky luminance of 1 kcd/m^2
+	sun_irradiance = 3.0  // light falling on a surface facing the sun outside the atmosphere
+	sun_radius     = 0.265
+)
+
+// Sky_light is the Preetham daylight model: an analytic fit of the clear sky
+// for a sun at the given elevation and azimuth in degrees, and turbidity from
+// 2 (very clear) to about 10 (hazy). Azimuth is measured clockwise from north,
+// which is -z, so east is +x. The sun is a disk of its real size whose color
+// comes from the light lost crossing the atmosphere. Below the horizon the
+// sky is mirrored and darkened by Ground, standing in for the earth.
+//
+// The sky is sampled from a map baked from the model, and the sun, which is
+// far brighter but tiny, from the cone it fills.
+type Sky_light struct {
+	Intensity      float64
+	Ground         Color // albedo of the ground below the horizon
+	sun_direction  Vec3
+	zenith         [3]float64    // luminance Y and chromaticities x, y at the zenith
+	perez          [3][5]float64 // distribution coefficients A to E for Y, x and y
+	perez_sun      [3]float64    // distribution value at the zenith, to normalize with
+	sun_radiance   Color
+	cos_sun_radius float64
+	sun_frame      Onb
+	sun_pick       float64 // chance of sampling the sun rather than the sky
+	sky            Environment_light
+}
+
+func NewSky_light(elevation float64, azimuth float64, turbidity float64) Sky_light {
+
+	el := Degrees_to_radians(elevation)
+	sun := Sun_position{Azimuth: azimuth, Elevation: elevation}.Direction()
+
+	// The fit only holds with the sun above the horizon
+	theta_s := math.Min(Pi/2-el, Pi/2)
+	t := turbidity
+
+	l := Sky_light{Intensity: 1, Ground: NewColor(0.3, 0.3, 0.3), sun_direction: sun}
+
+	chi := (4.0/9 - t/120) * (Pi - 2*theta_s)
+	l.zenith[0] = (4.0453*t-4.9710)*math.Tan(chi) - 0.2155*t + 2.4192
+	l.zenith[1] = zenith_chromaticity(t, theta_s, [3][4]float64{
+		{0.00166, -0.00375, 0.00209, 0},
+		{-0.02903, 0.06377, -0.03202, 0.00394},
+		{0.11693, -0.21196, 0.06052, 0.25886},
+	})
+	l.zenith[2] = zenith_chromaticity(t, theta_s, [3][4]float64{
+		{0.00275, -0.00610, 0.00317, 0},
+		{-0.04214, 0.08970, -0.04153, 0.00516},
+		{0.15346, -0.26756, 0.06670, 0.26688},
+	})
+
+	l.perez = [3][5]float64{
+		{0.1787*t - 1.4630, -0.3554*t + 0.4275, -0.0227*t + 5.3251, 0.1206*t - 2.5771, -0.0670*t + 0.3703},
+		{-0.0193*t - 0.2592, -0.0665*t + 0.0008, -0.0004*t + 0.2125, -0.0641*t - 0.8989, -0.0033*t + 0.0452},
+		{-0.0167*t - 0.2608, -0.0950*t + 0.0092, -0.0079*t + 0.2102, -0.0441*t - 1.6537, -0.0109*t + 0.0529},
+	}
+	for i := range l.perez_sun {
+		l.perez_sun[i] = perez(l.perez[i], 1, theta_s)
+	}
+
+	l.cos_sun_radius = math.Cos(Degrees_to_radians(sun_radius))
+	l.sun_frame = NewOnb(sun)
+
+	if elevation > -sun_radius {
+		solid_angle := 2 * Pi * (1 - l.cos_sun_radius)
+		l.sun_radiance = sun_transmittance(theta_s, t).Mult(sun_irradiance / solid_angle)
+		l.sun_pick = 0.5
+	}
+
+	// Bake the sky without the sun into a map to sample it from. Changing
+	// Ground later only makes the map a little less accurate.
+	image := Hdr_image{Width: 256, Height: 128, Pixels: make([]Color, 256*128)}
+	for y := 0; y < image.Height; y++ {
+		theta := Pi * (float64(y) + 0.5) / float64(image.Height)
+		for x := 0; x < image.Width; x++ {
+			phi := 2*Pi*(float64(x)+0.5)/float64(image.Width) - Pi
+			direction := NewVec3(math.Cos(phi)*math.Sin(theta), math.Cos(theta), -math.Sin(phi)*math.Sin(theta))
+			image.Pixels[y*image.Width+x] = l.sky_radiance(direction)
+		}
+	}
+	l.sky = NewEnvironment_light(image, 1, 0)
+
+	return l
+}
+
+// Le is the radiance of the sky and sun seen along direction
+func (l *Sky_light) Le(direction Vec3) Color {
+
+	d := Unit_vector(direction)
+	le := l.sky_radiance(d)
+
+	if Dot(d, l.sun_direction) >= l.cos_sun_radius && d.Y() >= 0 {
+		le = le.Add(l.sun_radiance)
+	}
+
+	return le.Mult(l.Intensity)
+}
+
+func (l *Sky_light) Sample_li(p Point3) (Light_sample, bool) {
+
+	var direction Vec3
+
+	if rand.Float64() < l.sun_pick {
+		// Uniformly within the cone of the sun
+		cos_theta := 1 - rand.Float64()*(1-l.cos_sun_radius)
+		sin_theta := math.Sqrt(math.Max(0, 1-cos_theta*cos_theta))
+		phi := 2 * Pi * rand.Float64()
+		direction = l.sun_frame.Local(NewVec3(math.Cos(phi)*sin_theta, math.Sin(phi)*sin_theta, cos_theta))
+	} else {
+		ls, ok := l.sky.Sample_li(p)
+		if !ok {
+			return Light_sample{}, false
+		}
+		direction = ls.Direction
+	}
+
+	pdf := l.Pdf_li(p, direction)
+	if pdf == 0 {
+		return Light_sample{}, false
+	}
+
+	return Light_sample{
+		Direction: direction,
+		Distance:  Infinity,
+		Radiance:  l.Le(direction),
+		Pdf:       pdf,
+	}, true
+}
+
+func (l *Sky_light) Pdf_li(p Point3, direction Vec3) float64 {
+
+	pdf := (1 - l.sun_pick) * l.sky.Pdf_li(p, direction)
+
+	if Dot(Unit_vector(direction), l.sun_direction) >= l.cos_sun_radius {
+		pdf += l.sun_pick / (2 * Pi * (1 - l.cos_sun_radius))
+	}
+
+	return pdf
+}
+
+// Sun_direction points from the scene towards the sun
+func (l *Sky_light) Sun_direction() Vec3 {
+	return l.sun_direction
+}
+
+// sky_radiance evaluates the model for a unit direction, without the sun
+func (l *Sky_light) sky_radiance(d Vec3) Color {
+
+	if d.Y() < 0 {
+		mirrored := NewVec3(d.X(), -d.Y(), d.Z())
+		return ComponentMultiply(l.Ground, l.sky_radiance(mirrored))
+	}
+
+	cos_theta := math.Max(d.Y(), 0.001)
+	gamma := math.Acos(math.Max(-1, math.Min(1, Dot(d, l.sun_direction))))
+
+	var value [3]float64
+	for i := range value {
+		value[i] = l.zenith[i] * perez(l.perez[i], cos_theta, gamma) / l.perez_sun[i]
+	}
+
+	// From luminance and chromaticity to linear sRGB
+	Y, x, y := value[0], value[1], value[2]
+	X := x / y * Y
+	Z := (1 - x - y) / y * Y
+
+	r := 3.2406*X - 1.5372*Y - 0.4986*Z
+	g := -0.9689*X + 1.8758*Y + 0.0415*Z
+	b := 0.0557*X - 0.2040*Y + 1.0570*Z
+
+	return NewColor(math.Max(0, r), math.Max(0, g), math.Max(0, b)).Mult(sky_scale)
+}
+
+// perez is the Perez distribution of luminance for a direction at cos_theta
+// from the zenith and gamma radians from the sun
+func perez(c [5]float64, cos_theta float64, gamma float64) float64 {
+	cos_gamma := math.Cos(gamma)
+	return (1 + c[0]*math.Exp(c[1]/cos_theta)) * (1 + c[2]*math.Exp(c[3]*gamma) + c[4]*cos_gamma*cos_gamma)
+}
+
+// zenith_chromaticity evaluates T^2, T and 1 rows of cubics in theta_s
+func zenith_chromaticity(t float64, theta_s float64, m [3][4]float64) float64 {
+	cubic := func(c [4]float64) float64 {
+		return ((c[0]*theta_s+c[1])*theta_s+c[2])*theta_s + c[3]
+	}
+	return t*t*cubic(m[0]) + t*cubic(m[1]) + cubic(m[2])
+}
+
+// sun_transmittance is the fraction of red, green and blue sunlight that
+// survives Rayleigh and aerosol scattering on the way down to the ground
+func sun_transmittance(theta_s float64, turbidity float64) Color {
+
+	degrees := theta_s * 180 / Pi
+	mass := 1 / (math.Cos(theta_s) + 0.15*math.Pow(93.885-degrees, -1.253))
+
+	beta := 0.04608*turbidity - 0.04586
+	wavelengths := [3]float64{0.65, 0.55, 0.45} // micrometers
+
+	var tau [3]float64
+	for i, lambda := range wavelengths {
+		rayleigh := math.Exp(-0.008735 * mass * math.Pow(lambda, -4.08))
+		aerosol := math.Exp(-beta * mass * math.Pow(lambda, -1.3))
+		tau[i] = rayleigh * aerosol
+	}
+
+	return NewColor(tau[0], tau[1], tau[2])
+}
@@ -0,0 +1,66 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Spectral_path_tracer is the path tracer following light at a few sampled
+// wavelengths instead of RGB, so that dispersive glass (see
+// NewDispersive_dielectric) splits white light into colors. Materials and
+// lights still work in RGB: their colors are upsampled to spectra at each
+// vertex, and the light found is converted back to RGB through CIE XYZ.
+type Spectral_path_tracer struct{}
+
+func (p *Spectral_path_tracer) Li(c *Camera, r *Ray, world Hittable) Color {
+	wavelengths := Sample_wavelengths(rand.Float64())
+	return wavelengths.To_rgb(trace_spectral(c, r, c.Max_depth, world, &wavelengths))
+}
+
+// trace_spectral is trace carrying wavelengths
+func trace_spectral(c *Camera, r *Ray, depth int, world Hittable, wavelengths *Wavelengths) Sampled_spectrum {
+	path := spectral_path{radiance: NewSampled_spectrum(0), throughput: NewSampled_spectrum(1), wavelengths: wavelengths}
+	follow_path(c, r, depth, world, &path, nil)
+	return path.radiance
+}
+
+// spectral_path carries light at a few wavelengths. Every other wavelength is
+// dropped at the first dispersive bounce, which follows the hero alone.
+// Direct light is upsampled as a whole rather than per factor, which is
+// close enough for the smooth spectra RGB colors give.
+type spectral_path struct {
+	radiance    Sampled_spectrum
+	throughput  Sampled_spectrum
+	wavelengths *Wavelengths
+}
+
+func (p *spectral_path) add(light Color) {
+	p.radiance = p.radiance.Add(Spectrum_multiply(p.throughput, p.wavelengths.Upsample(light)))
+}
+
+func (p *spectral_path) attenuate(weight Color) {
+	p.throughput = Spectrum_multiply(p.throughput, p.wavelengths.Upsample(weight))
+}
+
+func (p *spectral_path) scatter(bs Bsdf_sample) {
+	if bs.Flags&Bsdf_dispersive != 0 {
+		p.wavelengths.Terminate_secondary()
+	}
+	p.attenuate(bs.Weight)
+}
+
+func (p *spectral_path) roulette() bool {
+	survival := math.Min(1, p.throughput.Max())
+	if rand.Float64() >= survival {
+		return false
+	}
+	p.throughput = p.throughput.Mult(1 / survival)
+	return true
+}
+
+func (p *spectral_path) wavelength() float64 {
+	return p.wavelengths.Hero()
+}
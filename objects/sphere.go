@@ -23,6 +23,8 @@ func NewSphere(center Point3, radius float64, material Material) Sphere {
 }
 
 func (s *Sphere) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+	r.Count_primitive()
+
 	oc := r.Origin.Sub(s.Center)
 	a := r.Direction.Length_squared()
 	half_b := Dot(oc, r.Direction)
@@ -56,11 +58,29 @@ func (s *Sphere) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 	// fmt.Print(rec.U, rec.V, "\n")
 
 	rec.Mat = &s.Mat
+	rec.Object = s
 
 	return true
 
 }
 
+func (s *Sphere) Occluded(r *Ray, ray_t Interval) bool {
+	r.Count_primitive()
+
+	oc := r.Origin.Sub(s.Center)
+	a := r.Direction.Length_squared()
+	half_b := Dot(oc, r.Direction)
+	c := oc.Length_squared() - s.Radius*s.Radius
+
+	discriminant := half_b*half_b - a*c
+	if discriminant < 0 {
+		return false
+	}
+	sqrtd := math.Sqrt(discriminant)
+
+	return ray_t.Surrounds((-half_b-sqrtd)/a) || ray_t.Surrounds((-half_b+sqrtd)/a)
+}
+
 func (s *Sphere) Get_sphere_uv(p *Point3, u *float64, v *float64) {
 
 	theta := math.Acos(-p.Y())
@@ -76,3 +96,56 @@ func (s *Sphere) Get_sphere_uv(p *Point3, u *float64, v *float64) {
 func (s *Sphere) Bounding_box() Aabb {
 	return s.Bbox
 }
+
+func (s *Sphere) Area() float64 {
+	return 4 * Pi * s.Radius * s.Radius
+}
+
+// Sample_surface picks a point uniformly over the sphere's surface
+func (s *Sphere) Sample_surface() Hit_record {
+	var rec Hit_record
+
+	outward_normal := Random_unit_vector()
+	rec.P = s.Center.Add(outward_normal.Mult(s.Radius))
+	rec.Normal = outward_normal
+	rec.Front_face = true
+	s.Get_sphere_uv(&outward_normal, &rec.U, &rec.V)
+	rec.Mat = &s.Mat
+	rec.Object = s
+
+	return rec
+}
+
+// Pdf_value is the solid angle density of Random(origin) generating direction
+func (s *Sphere) Pdf_value(origin Point3, direction Vec3) float64 {
+	var rec Hit_record
+	r := NewRay(origin, direction)
+	if !s.Hit(&r, NewInterval(0.001, Infinity), &rec) {
+		return 0
+	}
+
+	distance_squared := s.Center.Sub(origin).Length_squared()
+	if distance_squared <= s.Radius*s.Radius {
+		return 1 / (4 * Pi)
+	}
+
+	cos_theta_max := math.Sqrt(1 - s.Radius*s.Radius/distance_squared)
+	solid_angle := 2 * Pi * (1 - cos_theta_max)
+
+	return 1 / solid_angle
+}
+
+// Random returns a direction from origin towards the sphere, uniformly
+// distributed over the cone of directions it subtends
+func (s *Sphere) Random(origin Point3) Vec3 {
+	direction := s.Center.Sub(origin)
+	distance_squared := direction.Length_squared()
+
+	// From inside the sphere every direction hits it
+	if distance_squared <= s.Radius*s.Radius {
+		return Random_unit_vector()
+	}
+
+	uvw := NewOnb(direction)
+	return uvw.Local(Random_to_sphere(s.Radius, distance_squared))
+}
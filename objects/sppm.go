@@ -0,0 +1,360 @@
+package objects
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Stochastic progressive photon mapping. A plain photon map blurs by its
+// fixed radius no matter how many photons are shot. Here each pass traces
+// one camera path per pixel to its first diffuse or glossy hit (the visible
+// point), shoots a fresh batch of photons, and gathers them at the visible
+// points. Each pixel keeps its own radius, which shrinks as photons arrive
+// so the estimate converges to the correct image.
+//
+// All light other than the direct lighting at the visible point comes from
+// photons, so only lights that photons start from (see Emitter) illuminate
+// indirectly.
+
+// sppm_alpha is the fraction of the newly gathered photons kept each pass;
+// lower values shrink the radius faster
+const sppm_alpha = 2.0 / 3.0
+
+type visible_point struct {
+	found bool
+	ray   Ray // camera ray arriving at the point
+	rec   Hit_record
+	beta  Color // path throughput from the camera
+}
+
+type sppm_pixel struct {
+	radius float64
+	ld     Color   // direct light summed over passes
+	n      float64 // photons accumulated so far
+	tau    Color   // flux accumulated so far
+	phi    Color   // flux gathered this pass
+	m      int     // photons gathered this pass
+	vp     visible_point
+	lock   sync.Mutex
+}
+
+// sppm_camera_path follows a camera ray through specular bounces to the
+// visible point, returning the light found directly along the way. Delta
+// lobes sampled on partly delta materials count as specular bounces.
+func (c *Camera) sppm_camera_path(r Ray, world Hittable) (Color, visible_point) {
+
+	ld := NewColor(0, 0, 0)
+	beta := NewColor(1, 1, 1)
+	ray := r
+
+	// Filled objects the path is inside
+	var inside media
+
+	for bounce := 0; bounce < c.Max_depth; bounce++ {
+		var rec Hit_record
+
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			ld = ld.Add(ComponentMultiply(beta, c.escaped(&ray, 0)))
+			break
+		}
+
+		if len(inside) > 0 {
+			var weight Color
+			var ok bool
+			ray, rec, inside, weight, ok = cross_media(world, ray, rec, inside)
+			if !ok {
+				break
+			}
+			beta = ComponentMultiply(beta, weight)
+		}
+
+		m := *rec.Mat
+
+		// Every earlier bounce was specular, so emission counts in full
+		ld = ld.Add(ComponentMultiply(beta, m.Emitted(rec.U, rec.V, &rec.P)))
+
+		var bs Bsdf_sample
+		var ok bool
+
+		if !m.Flags().Is_delta() {
+			// The photons are gathered through Eval, which leaves out the
+			// delta lobes of a partly delta material. A path that samples one
+			// walks on through it, and the visible point made the rest of the
+			// time stands for both.
+			chance := 0.0
+			if partly, is_partly := m.(Partly_delta); is_partly {
+				chance = partly.Delta_chance(&ray, &rec)
+			}
+			if chance > 0 {
+				bs, ok = m.Sample(&ray, &rec)
+			}
+
+			if !ok || bs.Flags&Bsdf_specular == 0 {
+				if chance >= 1 {
+					break
+				}
+				vp_beta := beta.Div(1 - chance)
+				ld = ld.Add(ComponentMultiply(vp_beta, c.direct_light(&ray, &rec, m, world, inside)))
+				return ld, visible_point{found: true, ray: ray, rec: rec, beta: vp_beta}
+			}
+		} else if bs, ok = m.Sample(&ray, &rec); !ok {
+			break
+		}
+
+		beta = ComponentMultiply(beta, bs.Weight)
+
+		if filled, ok := m.(Interior); ok && bs.Flags&Bsdf_transmission != 0 {
+			inside = inside.cross(m, filled, &rec)
+		}
+
+		ray = scattered_ray(&rec, bs)
+	}
+
+	return ld, visible_point{}
+}
+
+// direct_light estimates the light arriving directly at a hit by combining a
+// light sample with a BSDF sample, each weighted with the power heuristic.
+// Emitters that are not sampled lights, and the Background color, are only
+// found by the BSDF sample. inside is the media the hit was reached through.
+func (c *Camera) direct_light(r *Ray, rec *Hit_record, m Material, world Hittable, inside media) Color {
+
+	radiance := NewColor(0, 0, 0)
+
+	if len(c.Lights) > 0 {
+		radiance = c.sample_lights(r, rec, m, world, inside)
+	}
+
+	bs, ok := m.Sample(r, rec)
+	if !ok || bs.Pdf == 0 {
+		return radiance
+	}
+
+	var light_rec Hit_record
+	ray := scattered_ray(rec, bs)
+	weight := bs.Weight
+
+	bsdf_pdf := bs.Pdf
+	if bs.Flags&Bsdf_specular != 0 {
+		bsdf_pdf = 0
+	}
+
+	if !world.Hit(&ray, NewInterval(0.001, Infinity), &light_rec) {
+		return radiance.Add(ComponentMultiply(weight, c.escaped(&ray, bsdf_pdf)))
+	}
+
+	if beyond := inside.beyond(m, rec, bs.Direction); len(beyond) > 0 {
+		sampled := ray
+
+		var transmittance Color
+		ray, light_rec, _, transmittance, ok = cross_media(world, ray, light_rec, beyond)
+		if !ok {
+			return radiance
+		}
+		weight = ComponentMultiply(weight, transmittance)
+
+		// As in follow_path, no shadow ray finds light past a scattering
+		// event or an ignored surface
+		if ray.Origin != sampled.Origin || ray.Direction != sampled.Direction {
+			bsdf_pdf = 0
+		}
+	}
+
+	light_mat := *light_rec.Mat
+	emitted := light_mat.Emitted(light_rec.U, light_rec.V, &light_rec.P)
+
+	if light := c.light_for(light_rec.Object); light != nil && !lights_hit(rec.Lit_by(), light) {
+		emitted = NewColor(0, 0, 0)
+	} else if light != nil && bsdf_pdf > 0 {
+		light_pdf := light.Pdf_li(ray.Origin, ray.Direction) / float64(len(c.Lights))
+		emitted = emitted.Mult(Power_heuristic(bsdf_pdf, light_pdf))
+	}
+
+	return radiance.Add(ComponentMultiply(weight, emitted))
+}
+
+// sppm_photon traces one photon from light, depositing it at every visible
+// point whose radius it lands within after the first bounce. Photons whose
+// first surface is not linked to the light (see Visibility) are dropped.
+func (c *Camera) sppm_photon(world Hittable, pixels []sppm_pixel, grid *Kd_tree, owners []int, max_radius float64, ray Ray, power Color, light Emitter) {
+
+	// Filled objects the photon is inside
+	var inside media
+
+	for bounce := 0; bounce < c.Max_depth; bounce++ {
+		var rec Hit_record
+
+		if !world.Hit(&ray, NewInterval(0.001, Infinity), &rec) {
+			return
+		}
+
+		if bounce == 0 && !lights_hit(rec.Lit_by(), light) {
+			return
+		}
+
+		if len(inside) > 0 {
+			var weight Color
+			var ok bool
+			ray, rec, inside, weight, ok = cross_media(world, ray, rec, inside)
+			if !ok {
+				return
+			}
+			power = ComponentMultiply(power, weight)
+		}
+
+		m := *rec.Mat
+
+		// Light arriving straight from a light is handled by direct_light
+		if bounce > 0 && !m.Flags().Is_delta() {
+			direction := Unit_vector(ray.Direction.Mult(-1))
+
+			grid.Within(rec.P, max_radius, func(index int) {
+				pixel := &pixels[owners[index]]
+				if rec.P.Sub(pixel.vp.rec.P).Length_squared() >= pixel.radius*pixel.radius {
+					return
+				}
+
+				vp_mat := *pixel.vp.rec.Mat
+				flux := ComponentMultiply(bsdf_value(&pixel.vp.ray, &pixel.vp.rec, vp_mat, direction), power)
+
+				pixel.lock.Lock()
+				pixel.phi = pixel.phi.Add(flux)
+				pixel.m++
+				pixel.lock.Unlock()
+			})
+		}
+
+		bs, ok := m.Sample(&ray, &rec)
+		if !ok {
+			return
+		}
+
+		survival := 1.0
+		if c.Roulette_depth > 0 && bounce+1 >= c.Roulette_depth {
+			survival = math.Min(1, math.Max(bs.Weight.X(), math.Max(bs.Weight.Y(), bs.Weight.Z())))
+			if rand.Float64() >= survival {
+				return
+			}
+		}
+
+		power = ComponentMultiply(power, bs.Weight).Div(survival)
+
+		if filled, ok := m.(Interior); ok && bs.Flags&Bsdf_transmission != 0 {
+			inside = inside.cross(m, filled, &rec)
+		}
+
+		ray = scattered_ray(&rec, bs)
+	}
+}
+
+// RenderSPPM renders the scene with stochastic progressive photon mapping
+// over the given number of passes, shooting photons_per_pass photons in each.
+// Pixels start gathering within radius. The result is saved to output.png.
+func (c *Camera) RenderSPPM(world Hittable, passes int, photons_per_pass int, radius float64) {
+
+	start := time.Now()
+
+	c.initialize()
+	c.Collect_lights(world)
+
+	width, height := c.Image_width, c.image_height
+	pixels := make([]sppm_pixel, width*height)
+	for k := range pixels {
+		pixels[k].radius = radius
+	}
+
+	n_processes := 24
+	var wg sync.WaitGroup
+
+	for pass := 0; pass < passes; pass++ {
+
+		if c.Log_scanlines {
+			fmt.Printf("Pass %d of %d\n", pass+1, passes)
+		}
+
+		// Find this pass's visible points
+		for p := 0; p < n_processes; p++ {
+			wg.Add(1)
+			go func(start int, end int) {
+				defer wg.Done()
+				for j := start; j < end; j++ {
+					for i := 0; i < width; i++ {
+						pixel := &pixels[j*width+i]
+						ld, vp := c.sppm_camera_path(c.get_ray(i, j), world)
+						pixel.ld = pixel.ld.Add(ld)
+						pixel.vp = vp
+					}
+				}
+			}(p*height/n_processes, (p+1)*height/n_processes)
+		}
+		wg.Wait()
+
+		var points []Point3
+		var owners []int
+		max_radius := 0.0
+		for k := range pixels {
+			if pixels[k].vp.found {
+				points = append(points, pixels[k].vp.rec.P)
+				owners = append(owners, k)
+				max_radius = math.Max(max_radius, pixels[k].radius)
+			}
+		}
+		grid := NewKd_tree(points)
+
+		// Shoot photons at them
+		if len(c.emitters) > 0 && len(points) > 0 {
+			for p := 0; p < n_processes; p++ {
+				wg.Add(1)
+				go func(count int) {
+					defer wg.Done()
+					for k := 0; k < count; k++ {
+						ray, power, light, ok := c.emit_photon(photons_per_pass)
+						if ok {
+							c.sppm_photon(world, pixels, &grid, owners, max_radius, ray, power, light)
+						}
+					}
+				}((p+1)*photons_per_pass/n_processes - p*photons_per_pass/n_processes)
+			}
+			wg.Wait()
+		}
+
+		// Shrink the radius of every pixel that gathered photons, keeping
+		// the flux density consistent with the smaller disc
+		for k := range pixels {
+			pixel := &pixels[k]
+			if pixel.m > 0 {
+				n := pixel.n + sppm_alpha*float64(pixel.m)
+				r := pixel.radius * math.Sqrt(n/(pixel.n+float64(pixel.m)))
+				flux := pixel.tau.Add(ComponentMultiply(pixel.vp.beta, pixel.phi))
+
+				pixel.tau = flux.Mult((r * r) / (pixel.radius * pixel.radius))
+				pixel.n = n
+				pixel.radius = r
+			}
+			pixel.phi = NewColor(0, 0, 0)
+			pixel.m = 0
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			pixel := &pixels[j*width+i]
+			indirect := pixel.tau.Div(Pi * pixel.radius * pixel.radius)
+			Write_color(pixel.ld.Add(indirect), passes, img, i, j)
+		}
+	}
+
+	write_png(img, "output.png")
+
+	elapsed := time.Since(start)
+	fmt.Print("~~~~~~~~~~~~~~~~~~~~~~~~~~\nElapsed Time: ", elapsed, "\n~~~~~~~~~~~~~~~~~~~~~~~~~~\n")
+}
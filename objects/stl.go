@@ -74,7 +74,9 @@ func NewMeshFromReader(r io.Reader, material Material, scale float64) Hittable_l
 				z, _ = strconv.ParseFloat(fields[3], 64)
 				c := NewPoint3(x, y, z)
 
-				tri := Triangle(a.Mult(scale), b.Mult(scale), c.Mult(scale), material)
+				// STL corners run counter-clockwise seen from outside, the
+				// opposite of Triangle, so swap two to keep normals outward
+				tri := Triangle(a.Mult(scale), c.Mult(scale), b.Mult(scale), material)
 				triangles.Add(&tri)
 			}
 		}
@@ -120,7 +122,8 @@ func NewMeshFromReader(r io.Reader, material Material, scale float64) Hittable_l
 			v3z := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[44:48])))
 			c := NewPoint3(v3x, v3y, v3z)
 
-			tri := Triangle(a.Mult(scale), b.Mult(scale), c.Mult(scale), material)
+			// Counter-clockwise corners, see above
+			tri := Triangle(a.Mult(scale), c.Mult(scale), b.Mult(scale), material)
 			triangles.Add(&tri)
 		}
 	}
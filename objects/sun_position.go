@@ -0,0 +1,113 @@
+package objects
+
+import (
+	"math"
+	"time"
+
+	. "raytracer/common"
+)
+
+// Sun_position is where the sun appears from a place on earth, in degrees.
+// Azimuth is measured clockwise from north and Elevation up from the
+// horizon, including the lift from atmospheric refraction.
+type Sun_position struct {
+	Azimuth   float64
+	Elevation float64
+}
+
+// Solar_position uses the NOAA solar calculator's equations, which are good to
+// about a minute of arc for dates between 1800 and 2100. Latitude is positive
+// north and longitude positive east. The time zone comes from t, so a local
+// clock time is given as e.g. time.Date(2024, 6, 21, 12, 0, 0, 0,
+// time.FixedZone("", -6*3600)).
+func Solar_position(latitude float64, longitude float64, t time.Time) Sun_position {
+
+	utc := t.UTC()
+	julian_day := float64(utc.UnixNano())/(86400*1e9) + 2440587.5
+	centuries := (julian_day - 2451545) / 36525
+
+	sin := func(degrees float64) float64 { return math.Sin(Degrees_to_radians(degrees)) }
+	cos := func(degrees float64) float64 { return math.Cos(Degrees_to_radians(degrees)) }
+	degrees := func(radians float64) float64 { return radians * 180 / Pi }
+
+	// Orbit of the earth
+	mean_longitude := math.Mod(280.46646+centuries*(36000.76983+centuries*0.0003032), 360)
+	mean_anomaly := 357.52911 + centuries*(35999.05029-0.0001537*centuries)
+	eccentricity := 0.016708634 - centuries*(0.000042037+0.0000001267*centuries)
+
+	center := sin(mean_anomaly)*(1.914602-centuries*(0.004817+0.000014*centuries)) +
+		sin(2*mean_anomaly)*(0.019993-0.000101*centuries) +
+		sin(3*mean_anomaly)*0.000289
+
+	omega := 125.04 - 1934.136*centuries
+	apparent_longitude := mean_longitude + center - 0.00569 - 0.00478*sin(omega)
+
+	mean_obliquity := 23 + (26+(21.448-centuries*(46.815+centuries*(0.00059-centuries*0.001813)))/60)/60
+	obliquity := mean_obliquity + 0.00256*cos(omega)
+
+	declination := degrees(math.Asin(sin(obliquity) * sin(apparent_longitude)))
+
+	// Equation of time, in minutes
+	y := math.Pow(math.Tan(Degrees_to_radians(obliquity/2)), 2)
+	equation_of_time := 4 * degrees(y*sin(2*mean_longitude)-
+		2*eccentricity*sin(mean_anomaly)+
+		4*eccentricity*y*sin(mean_anomaly)*cos(2*mean_longitude)-
+		0.5*y*y*sin(4*mean_longitude)-
+		1.25*eccentricity*eccentricity*sin(2*mean_anomaly))
+
+	minutes := float64(utc.Hour()*60+utc.Minute()) + (float64(utc.Second())+float64(utc.Nanosecond())/1e9)/60
+	true_solar_time := math.Mod(minutes+equation_of_time+4*longitude, 1440)
+	if true_solar_time < 0 {
+		true_solar_time += 1440
+	}
+	hour_angle := true_solar_time/4 - 180
+
+	cos_zenith := sin(latitude)*sin(declination) + cos(latitude)*cos(declination)*cos(hour_angle)
+	zenith := degrees(math.Acos(math.Max(-1, math.Min(1, cos_zenith))))
+
+	azimuth := degrees(math.Atan2(sin(hour_angle), cos(hour_angle)*sin(latitude)-math.Tan(Degrees_to_radians(declination))*cos(latitude))) + 180
+	azimuth = math.Mod(azimuth, 360)
+
+	elevation := 90 - zenith
+
+	return Sun_position{Azimuth: azimuth, Elevation: elevation + refraction(elevation)}
+}
+
+// refraction is how far the atmosphere lifts the sun above its true
+// elevation, in degrees
+func refraction(elevation float64) float64 {
+
+	tan := math.Tan(Degrees_to_radians(elevation))
+
+	var arc_seconds float64
+	switch {
+	case elevation > 85:
+		arc_seconds = 0
+	case elevation > 5:
+		arc_seconds = 58.1/tan - 0.07/math.Pow(tan, 3) + 0.000086/math.Pow(tan, 5)
+	case elevation > -0.575:
+		arc_seconds = 1735 + elevation*(-518.2+elevation*(103.4+elevation*(-12.79+elevation*0.711)))
+	default:
+		arc_seconds = -20.772 / tan
+	}
+
+	return arc_seconds / 3600
+}
+
+// Direction points from the scene towards the sun, with north along -z and
+// east along +x as for Sky_light
+func (s Sun_position) Direction() Vec3 {
+	az := Degrees_to_radians(s.Azimuth)
+	el := Degrees_to_radians(s.Elevation)
+	return NewVec3(math.Sin(az)*math.Cos(el), math.Sin(el), -math.Cos(az)*math.Cos(el))
+}
+
+// Sky_light is a daylight sky with the sun in this position
+func (s Sun_position) Sky_light(turbidity float64) Sky_light {
+	return NewSky_light(s.Elevation, s.Azimuth, turbidity)
+}
+
+// Directional_light shines from this position with the given irradiance
+func (s Sun_position) Directional_light(irradiance Color) Directional_light {
+	return NewDirectional_light(s.Direction().Mult(-1), irradiance)
+}
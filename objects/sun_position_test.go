@@ -0,0 +1,65 @@
+package objects
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSolar_position(t *testing.T) {
+
+	// The first case is the worked example of NREL's Solar Position
+	// Algorithm (Reda and Andreas 2008), whose refraction assumes a lower
+	// pressure than NOAA's. The others were computed independently with the
+	// Astronomical Almanac's low precision formulas and Saemundsson's
+	// refraction, both good to about 0.01 degrees.
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		time      time.Time
+		azimuth   float64
+		elevation float64
+	}{
+		{"Golden autumn midday", 39.742476, -105.1786,
+			time.Date(2003, 10, 17, 12, 30, 30, 0, time.FixedZone("MST", -7*3600)), 194.34024, 90 - 50.11162},
+		{"London summer afternoon", 51.5, -0.13,
+			time.Date(2024, 6, 21, 16, 0, 0, 0, time.FixedZone("BST", 3600)), 247.514, 46.025},
+		{"Sydney winter noon", -33.87, 151.21,
+			time.Date(2024, 6, 21, 12, 0, 0, 0, time.FixedZone("AEST", 10*3600)), 359.181, 32.716},
+		{"Singapore equinox morning", 1.35, 103.82,
+			time.Date(2024, 3, 20, 9, 30, 0, 0, time.FixedZone("SGT", 8*3600)), 90.956, 34.471},
+		{"Tromso winter noon", 69.65, 18.96,
+			time.Date(2024, 2, 15, 12, 0, 0, 0, time.FixedZone("CET", 3600)), 180.423, 7.699},
+		{"New York sunrise", 40.71, -74.01,
+			time.Date(2024, 9, 22, 6, 50, 0, 0, time.FixedZone("EDT", -4*3600)), 90.207, 0.730},
+	}
+
+	for _, test := range tests {
+		sun := Solar_position(test.latitude, test.longitude, test.time)
+
+		if math.Abs(sun.Azimuth-test.azimuth) > 0.05 {
+			t.Errorf("%s: azimuth %.3f, want %.3f", test.name, sun.Azimuth, test.azimuth)
+		}
+		if math.Abs(sun.Elevation-test.elevation) > 0.05 {
+			t.Errorf("%s: elevation %.3f, want %.3f", test.name, sun.Elevation, test.elevation)
+		}
+	}
+}
+
+func TestRefraction(t *testing.T) {
+
+	// The piecewise fit should join up without jumps
+	for _, elevation := range []float64{-0.575, 5, 85} {
+		below := refraction(elevation - 1e-9)
+		above := refraction(elevation + 1e-9)
+		if math.Abs(below-above) > 0.01 {
+			t.Errorf("refraction jumps from %.4f to %.4f at %v degrees", below, above, elevation)
+		}
+	}
+
+	// About half a degree at the horizon
+	if r := refraction(0); math.Abs(r-0.482) > 0.01 {
+		t.Errorf("refraction at the horizon is %.3f, want 0.482", r)
+	}
+}
@@ -22,7 +22,7 @@ func NewTranslation(object Hittable, offset Vec3) Translation {
 
 func (tr Translation) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 
-	offset_r := NewRay(r.Origin.Sub(tr.offset), r.Direction)
+	offset_r := r.With(r.Origin.Sub(tr.offset), r.Direction)
 
 	if !tr.object.Hit(&offset_r, ray_t, rec) {
 		return false
@@ -34,6 +34,11 @@ func (tr Translation) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 
 }
 
+func (tr Translation) Occluded(r *Ray, ray_t Interval) bool {
+	offset_r := r.With(r.Origin.Sub(tr.offset), r.Direction)
+	return tr.object.Occluded(&offset_r, ray_t)
+}
+
 func (tr Translation) Bounding_box() Aabb {
 
 	return NewAabb(Universe, Universe, Universe)
@@ -47,7 +52,8 @@ type RotationY struct {
 	bbox      Aabb
 }
 
-func (rt RotationY) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+// rotate_ray takes a ray into the space of the rotated object
+func (rt RotationY) rotate_ray(r *Ray) Ray {
 
 	x := rt.cos_theta*r.Origin.X() - rt.sin_theta*r.Origin.Z()
 	z := rt.sin_theta*r.Origin.X() + rt.cos_theta*r.Origin.Z()
@@ -55,7 +61,17 @@ func (rt RotationY) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 	dir_x := rt.cos_theta*r.Direction.X() - rt.sin_theta*r.Direction.Z()
 	dir_z := rt.sin_theta*r.Direction.X() + rt.cos_theta*r.Direction.Z()
 
-	rotated_r := NewRay(NewPoint3(x, r.Origin.Y(), z), NewVec3(dir_x, r.Direction.Y(), dir_z))
+	return r.With(NewPoint3(x, r.Origin.Y(), z), NewVec3(dir_x, r.Direction.Y(), dir_z))
+}
+
+func (rt RotationY) Occluded(r *Ray, ray_t Interval) bool {
+	rotated_r := rt.rotate_ray(r)
+	return rt.object.Occluded(&rotated_r, ray_t)
+}
+
+func (rt RotationY) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+
+	rotated_r := rt.rotate_ray(r)
 
 	if !rt.object.Hit(&rotated_r, ray_t, rec) {
 		return false
@@ -127,7 +143,8 @@ type RotationX struct {
 	bbox      Aabb
 }
 
-func (rt RotationX) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+// rotate_ray takes a ray into the space of the rotated object
+func (rt RotationX) rotate_ray(r *Ray) Ray {
 
 	y := rt.cos_theta*r.Origin.Y() - rt.sin_theta*r.Origin.Z()
 	z := rt.sin_theta*r.Origin.Y() + rt.cos_theta*r.Origin.Z()
@@ -135,7 +152,17 @@ func (rt RotationX) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 	dir_y := rt.cos_theta*r.Direction.Y() - rt.sin_theta*r.Direction.Z()
 	dir_z := rt.sin_theta*r.Direction.Y() + rt.cos_theta*r.Direction.Z()
 
-	rotated_r := NewRay(NewPoint3(r.Origin.X(), y, z), NewVec3(r.Direction.X(), dir_y, dir_z))
+	return r.With(NewPoint3(r.Origin.X(), y, z), NewVec3(r.Direction.X(), dir_y, dir_z))
+}
+
+func (rt RotationX) Occluded(r *Ray, ray_t Interval) bool {
+	rotated_r := rt.rotate_ray(r)
+	return rt.object.Occluded(&rotated_r, ray_t)
+}
+
+func (rt RotationX) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+
+	rotated_r := rt.rotate_ray(r)
 
 	if !rt.object.Hit(&rotated_r, ray_t, rec) {
 		return false
@@ -206,7 +233,8 @@ type RotationZ struct {
 	bbox      Aabb
 }
 
-func (rt RotationZ) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+// rotate_ray takes a ray into the space of the rotated object
+func (rt RotationZ) rotate_ray(r *Ray) Ray {
 
 	x := rt.cos_theta*r.Origin.X() - rt.sin_theta*r.Origin.Y()
 	y := rt.sin_theta*r.Origin.X() + rt.cos_theta*r.Origin.Y()
@@ -214,7 +242,17 @@ func (rt RotationZ) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
 	dir_x := rt.cos_theta*r.Direction.X() - rt.sin_theta*r.Direction.Y()
 	dir_y := rt.sin_theta*r.Direction.X() + rt.cos_theta*r.Direction.Y()
 
-	rotated_r := NewRay(NewPoint3(x, y, r.Origin.Z()), NewVec3(dir_x, dir_y, r.Direction.Z()))
+	return r.With(NewPoint3(x, y, r.Origin.Z()), NewVec3(dir_x, dir_y, r.Direction.Z()))
+}
+
+func (rt RotationZ) Occluded(r *Ray, ray_t Interval) bool {
+	rotated_r := rt.rotate_ray(r)
+	return rt.object.Occluded(&rotated_r, ray_t)
+}
+
+func (rt RotationZ) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+
+	rotated_r := rt.rotate_ray(r)
 
 	if !rt.object.Hit(&rotated_r, ray_t, rec) {
 		return false
@@ -0,0 +1,87 @@
+package objects
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Visibility wraps an object to choose which kinds of ray can see it and
+// which lights illuminate it. An emissive object hidden from camera rays
+// still lights the scene, since lights are sampled directly. Light subpaths
+// and photons from a light the object is not linked to stop at it.
+type Visibility struct {
+	object  Hittable
+	Visible Ray_kind   // kinds of ray that hit the object
+	Lit_by  []Hittable // lights (emissive objects or delta lights) that illuminate it, all when nil
+}
+
+func NewVisibility(object Hittable, visible Ray_kind) Visibility {
+	return Visibility{object: object, Visible: visible}
+}
+
+// Link_lights makes the object lit only by the given lights. Infinite lights
+// such as the environment always illuminate it.
+func (v *Visibility) Link_lights(lights ...Hittable) {
+	v.Lit_by = lights
+}
+
+func (v *Visibility) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+
+	if r.Kind != 0 && r.Kind&v.Visible == 0 {
+		return false
+	}
+
+	if !v.object.Hit(r, ray_t, rec) {
+		return false
+	}
+
+	if v.Lit_by != nil {
+		rec.Link_lights(v.Lit_by)
+	}
+
+	return true
+}
+
+func (v *Visibility) Occluded(r *Ray, ray_t Interval) bool {
+
+	if r.Kind != 0 && r.Kind&v.Visible == 0 {
+		return false
+	}
+
+	return v.object.Occluded(r, ray_t)
+}
+
+func (v *Visibility) Bounding_box() Aabb {
+	return v.object.Bounding_box()
+}
+
+// Children lets Find_lights reach emissive objects inside
+func (v *Visibility) Children() []Hittable {
+	return []Hittable{v.object}
+}
+
+// lights_hit reports whether light illuminates a hit lit only by lit_by
+func lights_hit(lit_by []Hittable, light Light) bool {
+
+	if lit_by == nil {
+		return true
+	}
+
+	var object Hittable
+	switch l := light.(type) {
+	case *Area_light:
+		object = l.Shape
+	case Hittable:
+		object = l
+	default:
+		return true
+	}
+
+	for _, linked := range lit_by {
+		if linked == object {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package objects
+
+import (
+	"math"
+	"math/rand"
+
+	. "raytracer/common"
+	. "raytracer/material"
+)
+
+// Heterogeneous_medium is a participating medium whose density varies
+// through an axis aligned box, taken from a voxel grid stretched over it.
+//
+// Free flight distances cannot be sampled directly when density varies, so
+// Hit uses delta tracking: tentative collisions are drawn as if the whole box
+// had the grid's maximum density, and each is accepted as real with
+// probability density / maximum. Occluded uses ratio tracking, multiplying
+// the chance of passing each tentative collision into a transmittance.
+// Both are unbiased.
+type Heterogeneous_medium struct {
+	bounds      Aabb
+	min, size   Vec3
+	density     *Voxel_grid
+	scale       float64 // density of a grid value of one
+	max_density float64
+	material    Material
+}
+
+// volume_material scatters with the medium's phase function and adds the
+// medium's emission at every collision
+type volume_material struct {
+	Material
+	min, size Vec3
+	emission  *Voxel_grid
+	color     Color
+}
+
+func NewHeterogeneous_medium(min Point3, max Point3, density *Voxel_grid, scale float64, phase Material) Heterogeneous_medium {
+
+	size := max.Sub(min)
+
+	return Heterogeneous_medium{
+		bounds:      NewAabbFromPoints(min, max),
+		min:         min,
+		size:        size,
+		density:     density,
+		scale:       scale,
+		max_density: density.Max() * scale,
+		material:    &volume_material{Material: phase, min: min, size: size},
+	}
+}
+
+// Set_emission makes the medium glow. Each collision adds the emission grid
+// value times color, so the light given off scales with the density too.
+func (m *Heterogeneous_medium) Set_emission(emission *Voxel_grid, color Color) {
+	vm := m.material.(*volume_material)
+	vm.emission = emission
+	vm.color = color
+}
+
+func (vm *volume_material) Emitted(u float64, v float64, p *Point3) Color {
+	if vm.emission == nil {
+		return NewColor(0, 0, 0)
+	}
+	return vm.color.Mult(vm.emission.Lookup(to_unit_box(*p, vm.min, vm.size)))
+}
+
+func (m *Heterogeneous_medium) Hit(r *Ray, ray_t Interval, rec *Hit_record) bool {
+
+	span := ray_t
+	if m.max_density <= 0 || !m.bounds.Hit(r, &span) {
+		return false
+	}
+
+	ray_length := r.Direction.Length()
+	t := span.Min
+
+	for {
+		t -= math.Log(1-rand.Float64()) / (m.max_density * ray_length)
+		if t >= span.Max {
+			return false
+		}
+
+		p := r.At(t)
+		if rand.Float64()*m.max_density < m.density_at(p) {
+			rec.T = t
+			rec.P = p
+			rec.Normal = NewVec3(1, 0, 0)
+			rec.Front_face = true
+			rec.Mat = &m.material
+			rec.Object = m
+			return true
+		}
+	}
+}
+
+func (m *Heterogeneous_medium) Occluded(r *Ray, ray_t Interval) bool {
+
+	span := ray_t
+	if m.max_density <= 0 || !m.bounds.Hit(r, &span) {
+		return false
+	}
+
+	ray_length := r.Direction.Length()
+	t := span.Min
+	transmittance := 1.0
+
+	for transmittance > 0 {
+		t -= math.Log(1-rand.Float64()) / (m.max_density * ray_length)
+		if t >= span.Max {
+			break
+		}
+		transmittance *= 1 - m.density_at(r.At(t))/m.max_density
+	}
+
+	// Blocked with probability one minus the transmittance
+	return rand.Float64() >= transmittance
+}
+
+func (m *Heterogeneous_medium) density_at(p Point3) float64 {
+	return m.density.Lookup(to_unit_box(p, m.min, m.size)) * m.scale
+}
+
+// to_unit_box maps a point in the box at min with the given size to the unit
+// cube the grids span
+func to_unit_box(p Point3, min Point3, size Vec3) Point3 {
+	local := p.Sub(min)
+	return NewPoint3(local.X()/size.X(), local.Y()/size.Y(), local.Z()/size.Z())
+}
+
+func (m *Heterogeneous_medium) Bounding_box() Aabb {
+	return m.bounds
+}
@@ -0,0 +1,142 @@
+package objects
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	. "raytracer/common"
+)
+
+// Voxel_grid holds scalar values (density, emission) on an Nx x Ny x Nz
+// lattice stretched over the unit cube, with x varying fastest in Values
+type Voxel_grid struct {
+	Nx, Ny, Nz int
+	Values     []float64
+}
+
+func NewVoxel_grid(nx int, ny int, nz int) Voxel_grid {
+	return Voxel_grid{Nx: nx, Ny: ny, Nz: nz, Values: make([]float64, nx*ny*nz)}
+}
+
+func (g *Voxel_grid) At(i int, j int, k int) float64 {
+	return g.Values[(k*g.Ny+j)*g.Nx+i]
+}
+
+func (g *Voxel_grid) Set(i int, j int, k int, value float64) {
+	g.Values[(k*g.Ny+j)*g.Nx+i] = value
+}
+
+// Lookup interpolates trilinearly between the eight voxels around p, a point
+// in the unit cube. Voxel values sit at the voxel centers and the grid is
+// clamped at its edges.
+func (g *Voxel_grid) Lookup(p Point3) float64 {
+
+	x := p.X()*float64(g.Nx) - 0.5
+	y := p.Y()*float64(g.Ny) - 0.5
+	z := p.Z()*float64(g.Nz) - 0.5
+
+	i, fx := grid_cell(x, g.Nx)
+	j, fy := grid_cell(y, g.Ny)
+	k, fz := grid_cell(z, g.Nz)
+
+	i1, j1, k1 := min(i+1, g.Nx-1), min(j+1, g.Ny-1), min(k+1, g.Nz-1)
+
+	lerp := func(a float64, b float64, t float64) float64 {
+		return a + (b-a)*t
+	}
+
+	c00 := lerp(g.At(i, j, k), g.At(i1, j, k), fx)
+	c10 := lerp(g.At(i, j1, k), g.At(i1, j1, k), fx)
+	c01 := lerp(g.At(i, j, k1), g.At(i1, j, k1), fx)
+	c11 := lerp(g.At(i, j1, k1), g.At(i1, j1, k1), fx)
+
+	return lerp(lerp(c00, c10, fy), lerp(c01, c11, fy), fz)
+}
+
+// grid_cell splits a continuous voxel coordinate into the lower voxel index
+// and the fraction towards the next, clamped to the grid
+func grid_cell(x float64, n int) (int, float64) {
+	if x <= 0 {
+		return 0, 0
+	}
+	if x >= float64(n-1) {
+		return n - 1, 0
+	}
+	i := int(x)
+	return i, x - float64(i)
+}
+
+// Max is the largest value in the grid, which bounds every interpolated value
+func (g *Voxel_grid) Max() float64 {
+	max := 0.0
+	for _, value := range g.Values {
+		max = math.Max(max, value)
+	}
+	return max
+}
+
+// Load_voxel_grid reads a raw grid: three little endian uint32 sizes nx, ny
+// and nz followed by nx*ny*nz little endian float32 values, x varying fastest
+func Load_voxel_grid(path string) (Voxel_grid, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Voxel_grid{}, err
+	}
+	defer file.Close()
+
+	return Read_voxel_grid(bufio.NewReader(file))
+}
+
+func Read_voxel_grid(r io.Reader) (Voxel_grid, error) {
+
+	var size [3]uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return Voxel_grid{}, err
+	}
+
+	if size[0] == 0 || size[1] == 0 || size[2] == 0 || uint64(size[0])*uint64(size[1])*uint64(size[2]) > 1<<30 {
+		return Voxel_grid{}, fmt.Errorf("voxel grid: bad size %d x %d x %d", size[0], size[1], size[2])
+	}
+
+	values := make([]float32, int(size[0])*int(size[1])*int(size[2]))
+	if err := binary.Read(r, binary.LittleEndian, values); err != nil {
+		return Voxel_grid{}, err
+	}
+
+	grid := NewVoxel_grid(int(size[0]), int(size[1]), int(size[2]))
+	for i, value := range values {
+		grid.Values[i] = math.Max(0, float64(value))
+	}
+
+	return grid, nil
+}
+
+// NewNoise_grid fills an n^3 grid with turbulent Perlin noise at the given
+// frequency (features per unit cube), faded to zero towards the surface of
+// the cube's inscribed sphere so the cloud has no hard edges
+func NewNoise_grid(n int, frequency float64, octaves int) Voxel_grid {
+
+	grid := NewVoxel_grid(n, n, n)
+	noise := NewPerlin()
+	center := NewPoint3(0.5, 0.5, 0.5)
+
+	for k := 0; k < n; k++ {
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				p := NewPoint3((float64(i)+0.5)/float64(n), (float64(j)+0.5)/float64(n), (float64(k)+0.5)/float64(n))
+
+				falloff := math.Max(0, 1-p.Sub(center).Length()/0.5)
+				value := noise.Turb(p.Mult(frequency), octaves) * falloff
+
+				grid.Set(i, j, k, value)
+			}
+		}
+	}
+
+	return grid
+}
@@ -0,0 +1,135 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	. "raytracer/common"
+)
+
+func TestRead_voxel_grid(t *testing.T) {
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, [3]uint32{3, 2, 2})
+	values := make([]float32, 12)
+	for n := range values {
+		values[n] = float32(n)
+	}
+	// Negative densities are clamped to zero
+	values[5] = -1
+	binary.Write(&buf, binary.LittleEndian, values)
+
+	grid, err := Read_voxel_grid(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if grid.Nx != 3 || grid.Ny != 2 || grid.Nz != 2 {
+		t.Fatalf("size is %d x %d x %d, want 3 x 2 x 2", grid.Nx, grid.Ny, grid.Nz)
+	}
+
+	// x varies fastest, then y, then z
+	for k := 0; k < 2; k++ {
+		for j := 0; j < 2; j++ {
+			for i := 0; i < 3; i++ {
+				want := float64((k*2+j)*3 + i)
+				if i == 2 && j == 1 && k == 0 {
+					want = 0
+				}
+				if got := grid.At(i, j, k); got != want {
+					t.Errorf("voxel (%d, %d, %d) is %v, want %v", i, j, k, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRead_voxel_gridRejectsBadFiles(t *testing.T) {
+
+	sized := func(nx uint32, ny uint32, nz uint32, n_values int) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, [3]uint32{nx, ny, nz})
+		binary.Write(&buf, binary.LittleEndian, make([]float32, n_values))
+		return buf.Bytes()
+	}
+
+	tests := map[string][]byte{
+		"header":    {1, 0, 0, 0},
+		"empty":     sized(0, 1, 1, 0),
+		"huge":      sized(1<<12, 1<<12, 1<<12, 0),
+		"truncated": sized(2, 2, 2, 7),
+	}
+
+	for name, data := range tests {
+		if _, err := Read_voxel_grid(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestVoxel_gridLookup(t *testing.T) {
+
+	grid := NewVoxel_grid(2, 2, 2)
+	for k := 0; k < 2; k++ {
+		for j := 0; j < 2; j++ {
+			for i := 0; i < 2; i++ {
+				grid.Set(i, j, k, float64(i+2*j+4*k))
+			}
+		}
+	}
+
+	tests := []struct {
+		p    Point3
+		want float64
+	}{
+		// Voxel centers return the voxel itself
+		{NewPoint3(0.25, 0.25, 0.25), 0},
+		{NewPoint3(0.75, 0.25, 0.25), 1},
+		{NewPoint3(0.75, 0.75, 0.75), 7},
+		// Halfway between centers along each axis
+		{NewPoint3(0.5, 0.25, 0.25), 0.5},
+		{NewPoint3(0.25, 0.5, 0.25), 1},
+		{NewPoint3(0.5, 0.5, 0.5), 3.5},
+		// Clamped outside the outer centers
+		{NewPoint3(0, 0, 0), 0},
+		{NewPoint3(1, 1, 1), 7},
+		{NewPoint3(0.1, 0.9, 0.25), 2},
+	}
+
+	for _, test := range tests {
+		if got := grid.Lookup(test.p); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("Lookup(%v) is %v, want %v", test.p, got, test.want)
+		}
+	}
+
+	if got := grid.Max(); got != 7 {
+		t.Errorf("Max is %v, want 7", got)
+	}
+}
+
+func TestGrid_cell(t *testing.T) {
+
+	tests := []struct {
+		x        float64
+		n        int
+		i        int
+		fraction float64
+	}{
+		{-0.5, 4, 0, 0},
+		{0, 4, 0, 0},
+		{1.25, 4, 1, 0.25},
+		{2.5, 4, 2, 0.5},
+		{3, 4, 3, 0},
+		{3.5, 4, 3, 0},
+		{0.5, 1, 0, 0},
+	}
+
+	for _, test := range tests {
+		i, fraction := grid_cell(test.x, test.n)
+		if i != test.i || fraction != test.fraction {
+			t.Errorf("grid_cell(%v, %d) is (%d, %v), want (%d, %v)", test.x, test.n, i, fraction, test.i, test.fraction)
+		}
+	}
+}
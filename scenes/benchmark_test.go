@@ -0,0 +1,78 @@
+package scenes
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	. "raytracer/material"
+	. "raytracer/objects"
+)
+
+// render_stats renders the scene without writing an image and returns the
+// mean and per sample variance of the luminance
+func render_stats(cam *Camera, world Hittable) (float64, float64) {
+
+	cam.InitializeForWASM()
+	cam.Collect_lights(world)
+
+	height := max(int(float64(cam.Image_width)/cam.Aspect_ratio), 1)
+
+	var sum, sum_sq float64
+	for j := 0; j < height; j++ {
+		for i := 0; i < cam.Image_width; i++ {
+			for sample := 0; sample < cam.Sample_per_pixel; sample++ {
+				r := cam.GetRay(i, j)
+				color := cam.RayColor(&r, cam.Max_depth, world)
+
+				lum := 0.2126*color.X() + 0.7152*color.Y() + 0.0722*color.Z()
+				sum += lum
+				sum_sq += lum * lum
+			}
+		}
+	}
+
+	n := float64(cam.Image_width * height * cam.Sample_per_pixel)
+	mean := sum / n
+	return mean, math.Max(sum_sq/n-mean*mean, 0)
+}
+
+// BenchmarkRoulette renders Boxes and RandomSpheres at a reduced size with
+// and without Russian roulette. The means should agree within noise since
+// roulette is unbiased, while the time per render drops. Efficiency is the
+// inverse of variance times render time, so higher is better.
+func BenchmarkRoulette(b *testing.B) {
+
+	benchmarks := []struct {
+		name  string
+		scene func() (Hittable_list, Camera)
+	}{
+		{"Boxes", Boxes},
+		{"RandomSpheres", RandomSpheres},
+	}
+
+	for _, bench := range benchmarks {
+		world, cam := bench.scene()
+		bvh := NewBvh(world.Objects)
+
+		cam.Image_width = 100
+		cam.Sample_per_pixel = 8
+		cam.Log_scanlines = false
+
+		for _, depth := range []int{0, 3, 5} {
+			cam.Roulette_depth = depth
+
+			b.Run(fmt.Sprintf("%s/roulette=%d", bench.name, depth), func(b *testing.B) {
+				var mean, variance float64
+				for n := 0; n < b.N; n++ {
+					mean, variance = render_stats(&cam, &bvh)
+				}
+
+				seconds := b.Elapsed().Seconds() / float64(b.N)
+				b.ReportMetric(mean, "mean")
+				b.ReportMetric(variance, "variance")
+				b.ReportMetric(1/(variance*seconds), "efficiency")
+			})
+		}
+	}
+}
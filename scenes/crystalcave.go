@@ -26,6 +26,7 @@ func CrystalCave() (Hittable_list, Camera) {
 	cam.Focus_dist = 10.0
 	cam.Background = NewColor(0.04, 0.04, 0.06) // Dark but with slight ambient visibility
 	cam.Log_scanlines = true
+	cam.Integrator = &Spectral_path_tracer{}
 
 	// Cave floor - dark rocky surface
 	floorMat := NewLambertian(NewColor(0.05, 0.05, 0.06))
@@ -76,7 +77,7 @@ func CrystalCave() (Hittable_list, Camera) {
 	}
 
 	// Add some glass/diamond crystals (refractive)
-	glassMat := NewDielectric(2.4) // Diamond-like refraction
+	glassMat := NewDispersive_dielectric(Diamond) // Diamond refraction, with fire when rendered spectrally
 	
 	glassCrystalPositions := []Point3{
 		NewPoint3(1, 0, 2),
@@ -90,6 +91,11 @@ func CrystalCave() (Hittable_list, Camera) {
 		addCrystal(&world, pos, height, baseSize, &glassMat)
 	}
 
+	// Frosted ice crystals
+	iceMat := NewRough_dielectric(1.31, 0.35)
+	addCrystal(&world, NewPoint3(-3.5, 0, -0.5), 2.2, 0.45, &iceMat)
+	addCrystal(&world, NewPoint3(2.5, 0, 3.5), 1.4, 0.3, &iceMat)
+
 	// Add ceiling stalactite crystals (hanging down, glowing)
 	stalactitePositions := []Point3{
 		NewPoint3(-2, 12, 1),
@@ -118,8 +124,9 @@ func CrystalCave() (Hittable_list, Camera) {
 
 	// Small accent spheres (like crystal orbs)
 	orbMat := NewDielectric(1.5)
+	frostedMat := NewRough_dielectric(1.5, 0.2)
 	orb1 := NewSphere(NewPoint3(-1, 0.5, 7), 0.5, &orbMat)
-	orb2 := NewSphere(NewPoint3(2, 0.4, 6), 0.4, &orbMat)
+	orb2 := NewSphere(NewPoint3(2, 0.4, 6), 0.4, &frostedMat)
 	world.Add(&orb1)
 	world.Add(&orb2)
 
@@ -1,6 +1,8 @@
 package scenes
 
 import (
+	"fmt"
+
 	. "raytracer/common"
 	. "raytracer/material"
 	. "raytracer/objects"
@@ -46,12 +48,12 @@ func Earth() (Hittable_list, Camera) {
 
 	world.Add(&abc)
 
-	sky_tex := NewImage_texture("assets/skysphere.jpg")
-	sky_mat := NewTexturedDiffuse_Light(&sky_tex, 1.0)
-
-	sky := NewSphere(NewPoint3(0, 0, 0), 100000, &sky_mat)
-
-	world.Add(&sky)
+	sky, err := Load_environment_light("assets/skysphere.jpg", 1.0, 0)
+	if err != nil {
+		fmt.Print("WARNING: Invalid environment path specified", err)
+	} else {
+		c.Environment = &sky
+	}
 
 	return world, c
 
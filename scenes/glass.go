@@ -0,0 +1,71 @@
+package scenes
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+	. "raytracer/objects"
+)
+
+// Glass shows absorbing and nested dielectrics: three spheres of the same
+// tinted glass, darker the thicker they are, and ice cubes floating in a
+// tank of water. The cubes overlap the water and take priority over it, so
+// they refract against water below the surface and against air above it.
+func Glass() (Hittable_list, Camera) {
+
+	var world Hittable_list
+
+	cam := NewCamera()
+
+	cam.Aspect_ratio = 16.0 / 9.0
+	cam.Image_width = 400
+	cam.Sample_per_pixel = 256
+	cam.Max_depth = 40
+	cam.Background = NewColor(0.5, 0.55, 0.6)
+
+	cam.Vfov = 35
+	cam.Look_from = NewPoint3(0, 7, 18)
+	cam.Look_at = NewPoint3(0, 1.2, 0)
+	cam.Vup = NewVec3(0, 1, 0)
+
+	cam.Log_scanlines = true
+
+	floor_mat := NewLambertian(NewColor(0.75, 0.75, 0.7))
+	floor := NewQuad(NewPoint3(-50, 0, -50), NewVec3(100, 0, 0), NewVec3(0, 0, 100), &floor_mat)
+
+	// Transmits this color through 1 unit of glass
+	amber := NewTinted_dielectric(1.5, NewColor(0.95, 0.6, 0.25), 1)
+
+	small := NewSphere(NewPoint3(-6.5, 0.5, 2), 0.5, &amber)
+	medium := NewSphere(NewPoint3(-5, 1, 0), 1, &amber)
+	large := NewSphere(NewPoint3(-2.5, 1.5, -2.5), 1.5, &amber)
+
+	water := NewTinted_dielectric(1.33, NewColor(0.6, 0.85, 0.9), 4)
+	water.Priority = 1
+	// Lifted off the floor so the bottom face is not coplanar with it
+	tank := NewBox(NewPoint3(0, 0.01, -3), NewPoint3(6, 2, 3), &water)
+
+	ice := NewTinted_dielectric(1.31, NewColor(0.9, 0.96, 1), 4)
+	ice.Priority = 2
+	cube1 := NewBox(NewPoint3(-0.7, -0.7, -0.7), NewPoint3(0.7, 0.7, 0.7), &ice)
+	cube2 := NewBox(NewPoint3(-0.6, -0.6, -0.6), NewPoint3(0.6, 0.6, 0.6), &ice)
+
+	var ice1 Hittable = NewRotationY(&cube1, 25)
+	ice1 = NewTranslation(ice1, NewVec3(2, 2.1, 0.5))
+	var ice2 Hittable = NewRotationY(&cube2, -40)
+	ice2 = NewTranslation(ice2, NewVec3(4.2, 2.05, -1))
+
+	light_mat := NewDiffuse_light(NewColor(10, 10, 9))
+	light := NewQuad(NewPoint3(-4, 12, -2), NewVec3(8, 0, 0), NewVec3(0, 0, 6), &light_mat)
+
+	world.Add(&floor)
+	world.Add(&small)
+	world.Add(&medium)
+	world.Add(&large)
+	world.Add(&tank)
+	world.Add(ice1)
+	world.Add(ice2)
+	world.Add(&light)
+
+	return world, cam
+
+}
@@ -0,0 +1,102 @@
+package scenes
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+	. "raytracer/objects"
+)
+
+// Materials lines up spheres of the principled material under a daylight
+// sky: matte, glossy plastic, rough gold, red car paint with a clearcoat,
+// velvet with sheen and frosted glass. Behind them are GGX conductors:
+// polished gold, copper, silver and aluminium, and brushed chrome. In front
+// are coated materials: metallic car paint, varnished checkered wood, rough
+// gold under a clear coat and blue lacquer over white.
+func Materials() (Hittable_list, Camera) {
+
+	var world Hittable_list
+
+	cam := NewCamera()
+
+	cam.Aspect_ratio = 16.0 / 9.0
+	cam.Image_width = 400
+	cam.Sample_per_pixel = 128
+	cam.Max_depth = 16
+
+	cam.Vfov = 30
+	cam.Look_from = NewPoint3(0, 7, 16)
+	cam.Look_at = NewPoint3(0, 1, 0)
+	cam.Vup = NewVec3(0, 1, 0)
+
+	cam.Log_scanlines = true
+
+	sky := NewSky_light(35, 200, 3)
+	cam.Environment = &sky
+
+	c1 := NewColor(0.3, 0.3, 0.3)
+	c2 := NewColor(0.7, 0.7, 0.7)
+	checker := NewChecker_texture(1, &c1, &c2)
+	floor_mat := NewPrincipled(NewColor(1, 1, 1), 0, 0.7)
+	floor_mat.Base_color = &checker
+	floor := NewQuad(NewPoint3(-50, 0, -50), NewVec3(100, 0, 0), NewVec3(0, 0, 100), &floor_mat)
+	world.Add(&floor)
+
+	matte := NewPrincipled(NewColor(0.8, 0.8, 0.8), 0, 1)
+	plastic := NewPrincipled(NewColor(0.1, 0.3, 0.8), 0, 0.2)
+	gold := NewPrincipled(NewColor(1.0, 0.78, 0.34), 1, 0.35)
+
+	paint := NewPrincipled(NewColor(0.6, 0.02, 0.02), 0.6, 0.5)
+	coat := NewSolid_colorRGB(1, 1, 1)
+	paint.Clearcoat = &coat
+
+	velvet := NewPrincipled(NewColor(0.35, 0.05, 0.3), 0, 0.9)
+	sheen := NewSolid_colorRGB(1, 1, 1)
+	velvet.Sheen = &sheen
+
+	frosted := NewPrincipled(NewColor(0.9, 1.0, 0.95), 0, 0.25)
+	transmission := NewSolid_colorRGB(1, 1, 1)
+	frosted.Transmission = &transmission
+
+	for i, m := range []*Principled{&matte, &plastic, &gold, &paint, &velvet, &frosted} {
+		sphere := NewSphere(NewPoint3(-5.5+2.2*float64(i), 1, 0), 1, m)
+		world.Add(&sphere)
+	}
+
+	polished_gold := NewConductor(Gold, 0.15)
+	copper := NewConductor(Copper, 0.3)
+	silver := NewConductor(Silver, 0)
+	aluminium := NewConductor(Aluminium, 0.45)
+	brushed := NewAnisotropic_conductor(Chrome, 0.5, 0.08)
+
+	for i, m := range []*Conductor{&polished_gold, &copper, &silver, &aluminium, &brushed} {
+		sphere := NewSphere(NewPoint3(-4.4+2.2*float64(i), 1, -3), 1, m)
+		world.Add(&sphere)
+	}
+
+	metallic_red := NewPrincipled(NewColor(0.7, 0.05, 0.05), 1, 0.45)
+	car_paint := NewCoated(&metallic_red, 1.5, 0)
+
+	w1 := NewColor(0.45, 0.25, 0.12)
+	w2 := NewColor(0.3, 0.15, 0.07)
+	planks := NewChecker_texture(0.3, &w1, &w2)
+	wood := NewTexturedLambertian(&planks)
+	varnish := NewCoated(&wood, 1.5, 0.05)
+	varnish.Thickness = 0.05
+	varnish.Absorption = NewColor(1, 3, 8)
+
+	rough_gold := NewConductor(Gold, 0.5)
+	coated_gold := NewCoated(&rough_gold, 1.5, 0)
+
+	white := NewLambertian(NewColor(0.9, 0.9, 0.9))
+	lacquer := NewCoated(&white, 1.5, 0.1)
+	lacquer.Thickness = 0.1
+	lacquer.Absorption = NewColor(12, 5, 0.5)
+
+	for i, m := range []*Coated{&car_paint, &varnish, &coated_gold, &lacquer} {
+		sphere := NewSphere(NewPoint3(-3.3+2.2*float64(i), 0.7, 2.8), 0.7, m)
+		world.Add(&sphere)
+	}
+
+	return world, cam
+
+}
@@ -44,8 +44,12 @@ func Meshes() (Hittable_list, Camera) {
 
 	s4 := NewSphere(NewPoint3(150, 107, 4), 40, &sun_surface)
 
-	world.Add(&s3)
-	world.Add(&s4)
+	// The suns light the giraffe without filling the view
+	sun1 := NewVisibility(&s3, Ray_all&^Ray_camera)
+	sun2 := NewVisibility(&s4, Ray_all&^Ray_camera)
+
+	world.Add(&sun1)
+	world.Add(&sun2)
 
 	// s := NewSphere(NewPoint3(3, 3, 3), 1, &sun_surface)
 
@@ -0,0 +1,76 @@
+package scenes
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+	. "raytracer/objects"
+)
+
+// Mixes shows blended materials: copper with rust patches, a checker of
+// glass and diffuse, blue paint that turns into a mirror at grazing angles,
+// and a wall with glowing tiles painted onto it that light the scene
+func Mixes() (Hittable_list, Camera) {
+
+	var world Hittable_list
+
+	cam := NewCamera()
+
+	cam.Aspect_ratio = 16.0 / 9.0
+	cam.Image_width = 400
+	cam.Sample_per_pixel = 128
+	cam.Max_depth = 16
+	cam.Background = NewColor(0.05, 0.05, 0.07)
+
+	cam.Vfov = 35
+	cam.Look_from = NewPoint3(0, 4, 12)
+	cam.Look_at = NewPoint3(0, 1.2, 0)
+	cam.Vup = NewVec3(0, 1, 0)
+
+	cam.Log_scanlines = true
+
+	floor_mat := NewLambertian(NewColor(0.6, 0.6, 0.6))
+	floor := NewQuad(NewPoint3(-50, 0, -50), NewVec3(100, 0, 0), NewVec3(0, 0, 100), &floor_mat)
+
+	// Glowing tiles on a grey wall
+	plaster := NewLambertian(NewColor(0.5, 0.5, 0.5))
+	glow := NewDiffuse_light(NewColor(1.5, 1.2, 0.8))
+	off := NewColor(0, 0, 0)
+	on := NewColor(1, 1, 1)
+	tiles := NewChecker_texture(1, &off, &on)
+	wall_mat := NewTextured_mix(&plaster, &glow, &tiles)
+	wall := NewQuad(NewPoint3(-8, 0, -3.5), NewVec3(16, 0, 0), NewVec3(0, 6, 0), &wall_mat)
+
+	// Rust patches on copper
+	copper := NewConductor(Copper, 0.2)
+	rust := NewLambertian(NewColor(0.35, 0.12, 0.04))
+	dull := NewColor(0.2, 0.2, 0.2)
+	rusty := NewColor(0.9, 0.9, 0.9)
+	patches := NewChecker_texture(0.35, &dull, &rusty)
+	rusted := NewTextured_mix(&copper, &rust, &patches)
+
+	// Checker of glass and diffuse
+	glass := NewDielectric(1.5)
+	orange := NewLambertian(NewColor(0.8, 0.35, 0.1))
+	c1 := NewColor(0, 0, 0)
+	c2 := NewColor(1, 1, 1)
+	checker := NewChecker_texture(0.4, &c1, &c2)
+	glass_checker := NewTextured_mix(&glass, &orange, &checker)
+
+	// Paint that reflects like a mirror at grazing angles
+	blue := NewLambertian(NewColor(0.1, 0.2, 0.7))
+	mirror := NewMetal(NewColor(1, 1, 1), 0)
+	polished := NewFresnel_mix(&blue, &mirror, 1.5)
+
+	left := NewSphere(NewPoint3(-3, 1, 0), 1, &rusted)
+	middle := NewSphere(NewPoint3(0, 1, 0), 1, &glass_checker)
+	right := NewSphere(NewPoint3(3, 1, 0), 1, &polished)
+
+	world.Add(&floor)
+	world.Add(&wall)
+	world.Add(&left)
+	world.Add(&middle)
+	world.Add(&right)
+
+	return world, cam
+
+}
@@ -66,3 +66,21 @@ func Texturedspheres() (Hittable_list, Camera) {
 	return world, c
 
 }
+
+// PlanetNebula is the planet scene with a glowing nebula of procedural
+// noise drifting behind the planets
+func PlanetNebula() (Hittable_list, Camera) {
+
+	world, c := Texturedspheres()
+
+	cloud := NewNoise_grid(64, 3, 5)
+	dust := NewIsotropic(NewColor(0.8, 0.7, 0.9))
+
+	nebula := NewHeterogeneous_medium(NewPoint3(-97, -85, -55), NewPoint3(-27, -15, 15), &cloud, 0.25, &dust)
+	nebula.Set_emission(&cloud, NewColor(1.2, 0.35, 1.8))
+
+	world.Add(&nebula)
+
+	return world, c
+
+}
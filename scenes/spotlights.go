@@ -0,0 +1,57 @@
+package scenes
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+	. "raytracer/objects"
+)
+
+// Spotlights is lit only by delta lights: a low sun casting long hard
+// shadows, two colored spotlights with soft edged cones and a dim point light
+func Spotlights() (Hittable_list, Camera) {
+
+	var world Hittable_list
+
+	cam := NewCamera()
+
+	cam.Aspect_ratio = 16.0 / 9.0
+	cam.Image_width = 400
+	cam.Sample_per_pixel = 64
+	cam.Max_depth = 20
+	cam.Background = NewColor(0, 0, 0)
+
+	cam.Vfov = 40
+	cam.Look_from = NewPoint3(0, 6, 16)
+	cam.Look_at = NewPoint3(0, 1, 0)
+	cam.Vup = NewVec3(0, 1, 0)
+
+	cam.Log_scanlines = true
+
+	floor_mat := NewLambertian(NewColor(0.7, 0.7, 0.7))
+	floor := NewQuad(NewPoint3(-50, 0, -50), NewVec3(100, 0, 0), NewVec3(0, 0, 100), &floor_mat)
+
+	matte := NewLambertian(NewColor(0.8, 0.8, 0.8))
+	metal := NewMetal(NewColor(0.8, 0.6, 0.3), 0.2)
+	glass := NewDielectric(1.5)
+
+	left := NewSphere(NewPoint3(-3, 1, 0), 1, &matte)
+	middle := NewSphere(NewPoint3(0, 1, -1), 1, &metal)
+	right := NewSphere(NewPoint3(3, 1, 0), 1, &glass)
+
+	sun := NewDirectional_light(NewVec3(-1, -0.5, -0.6), NewColor(0.8, 0.75, 0.6))
+	red := NewSpot_light(NewPoint3(-6, 7, 4), NewPoint3(-3, 0, 0), NewColor(120, 20, 20), 25, 15)
+	blue := NewSpot_light(NewPoint3(6, 7, 4), NewPoint3(3, 0, 0), NewColor(20, 30, 140), 25, 5)
+	fill := NewPoint_light(NewPoint3(0, 4, 6), NewColor(4, 4, 4))
+
+	world.Add(&floor)
+	world.Add(&left)
+	world.Add(&middle)
+	world.Add(&right)
+	world.Add(&sun)
+	world.Add(&red)
+	world.Add(&blue)
+	world.Add(&fill)
+
+	return world, cam
+
+}
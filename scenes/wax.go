@@ -0,0 +1,52 @@
+package scenes
+
+import (
+	. "raytracer/common"
+	. "raytracer/material"
+	. "raytracer/objects"
+)
+
+// Wax shows subsurface scattering: an STL mesh of candle wax next to a jade
+// sphere, lit from one side so light can be seen bleeding through them
+func Wax() (Hittable_list, Camera) {
+
+	var world Hittable_list
+
+	cam := NewCamera()
+
+	cam.Aspect_ratio = 16.0 / 9.0
+	cam.Image_width = 400
+	cam.Sample_per_pixel = 256
+	cam.Max_depth = 40
+	cam.Background = NewColor(0.02, 0.02, 0.03)
+
+	cam.Vfov = 35
+	cam.Look_from = NewPoint3(0, 14, 42)
+	cam.Look_at = NewPoint3(0, 3, 0)
+	cam.Vup = NewVec3(0, 1, 0)
+
+	cam.Log_scanlines = true
+
+	floor_mat := NewLambertian(NewColor(0.6, 0.6, 0.6))
+	floor := NewQuad(NewPoint3(-60, -7, -60), NewVec3(120, 0, 0), NewVec3(0, 0, 120), &floor_mat)
+
+	wax := NewSubsurface(NewColor(0.95, 0.8, 0.55), NewColor(1.0, 0.7, 0.5), 1.45)
+	mesh := NewMeshFromFile("assets/ascii_mesh.stl", &wax, 0.7)
+	mesh_bvh := NewBvh(mesh.Objects)
+	var candle Hittable = NewRotationX(mesh_bvh, 90)
+	candle = NewTranslation(candle, NewVec3(-6, 0, 0))
+
+	jade := NewSubsurface(NewColor(0.3, 0.8, 0.45), NewColor(0.6, 0.9, 0.7), 1.6)
+	sphere := NewSphere(NewPoint3(7, -2, 0), 5, &jade)
+
+	light_mat := NewDiffuse_light(NewColor(12, 11, 10))
+	light := NewQuad(NewPoint3(-30, 5, -25), NewVec3(0, 20, 0), NewVec3(0, 0, 12), &light_mat)
+
+	world.Add(&floor)
+	world.Add(candle)
+	world.Add(&sphere)
+	world.Add(&light)
+
+	return world, cam
+
+}
@@ -5,6 +5,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"image"
 	"math"
 	"math/rand"
 	"syscall/js"
@@ -31,6 +32,10 @@ var renderState struct {
 	totalPixels   int
 	currentSample int  // For iterative refinement
 	initialized   bool
+
+	// Convergence animation snapshots
+	frames      []*image.RGBA
+	frameSample int // sample count at which the next snapshot is taken
 }
 
 // getScenes returns a list of available scene names
@@ -158,6 +163,7 @@ func initProgressiveRender() js.Func {
 
 		// Build BVH
 		bvh := NewBvh(world.Objects)
+		cam.Collect_lights(&bvh)
 
 		// Create shuffled indices for random pixel order within each sample pass
 		totalPixels := width * height
@@ -182,6 +188,8 @@ func initProgressiveRender() js.Func {
 		renderState.totalPixels = totalPixels
 		renderState.currentSample = 0
 		renderState.initialized = true
+		renderState.frames = nil
+		renderState.frameSample = 1
 
 		// Return info: totalPixels and totalSamples
 		return map[string]interface{}{
@@ -233,6 +241,8 @@ func renderSamplePass() js.Func {
 			renderState.pixels[pixIdx+3] = 255
 		}
 
+		recordFrame(sampleNum)
+
 		// Return updated pixel buffer
 		jsArray := js.Global().Get("Uint8ClampedArray").New(len(renderState.pixels))
 		js.CopyBytesToJS(jsArray, renderState.pixels)
@@ -305,6 +315,11 @@ func renderSampleChunk() js.Func {
 			renderState.pixels[pixIdx+3] = 255
 		}
 
+		// A sample pass is complete once the last chunk has been rendered
+		if endIdx == renderState.totalPixels {
+			recordFrame(sampleNum)
+		}
+
 		// Return updated pixel buffer
 		jsArray := js.Global().Get("Uint8ClampedArray").New(len(renderState.pixels))
 		js.CopyBytesToJS(jsArray, renderState.pixels)
@@ -313,6 +328,69 @@ func renderSampleChunk() js.Func {
 	})
 }
 
+// recordFrame snapshots the display buffer for the convergence animation.
+// Snapshots get sparser as the image converges to keep memory bounded.
+func recordFrame(sampleNum int) {
+	if sampleNum < renderState.frameSample {
+		return
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, renderState.width, renderState.height))
+	copy(img.Pix, renderState.pixels)
+	renderState.frames = append(renderState.frames, img)
+
+	renderState.frameSample = int(math.Max(float64(sampleNum+1), math.Ceil(float64(sampleNum)*1.25)))
+}
+
+// exportConvergence encodes the recorded snapshots as an animation
+// Args: format ("gif" or "apng"), delay in 100ths of a second, dither (gif only)
+func exportConvergence() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if !renderState.initialized || renderState.currentSample == 0 {
+			return nil
+		}
+
+		format := "gif"
+		delay := 15
+		dither := true
+
+		if len(args) >= 1 {
+			format = args[0].String()
+		}
+		if len(args) >= 2 {
+			delay = args[1].Int()
+		}
+		if len(args) >= 3 {
+			dither = args[2].Bool()
+		}
+
+		// Always end on the latest state of the render
+		frames := renderState.frames
+		last := image.NewRGBA(image.Rect(0, 0, renderState.width, renderState.height))
+		copy(last.Pix, renderState.pixels)
+		if len(frames) == 0 || !bytes.Equal(frames[len(frames)-1].Pix, last.Pix) {
+			frames = append(frames, last)
+		}
+
+		var buf bytes.Buffer
+		var err error
+		if format == "apng" {
+			err = Encode_apng(&buf, frames, delay)
+		} else {
+			err = Encode_gif(&buf, frames, delay, dither)
+		}
+		if err != nil {
+			js.Global().Get("console").Call("error", "Export failed: "+err.Error())
+			return nil
+		}
+
+		jsArray := js.Global().Get("Uint8Array").New(buf.Len())
+		js.CopyBytesToJS(jsArray, buf.Bytes())
+
+		return jsArray
+	})
+}
+
 func clamp(x, min, max float64) float64 {
 	if x < min {
 		return min
@@ -441,6 +519,7 @@ func main() {
 	js.Global().Set("goRenderChunk", renderChunk())
 	js.Global().Set("goRenderSamplePass", renderSamplePass())
 	js.Global().Set("goRenderSampleChunk", renderSampleChunk())
+	js.Global().Set("goExportConvergence", exportConvergence())
 
 	// Log that WASM is ready
 	js.Global().Get("console").Call("log", "Go WASM raytracer initialized")
@@ -464,9 +543,12 @@ func createFrogScene() (Hittable_list, Camera) {
 	cam.Vup = NewVec3(0, 1, 0)
 	cam.Defocus_angle = 0.6
 	cam.Focus_dist = 10.0
-	cam.Background = NewColor(0.7, 0.8, 1.0) // Skyish background
 	cam.Log_scanlines = false
 
+	// Daylight sky with the sun high in the south east
+	sky := NewSky_light(55, 135, 3)
+	cam.Environment = &sky
+
 	// Load embedded asset
 	frogData, err := GetEmbeddedAsset("lowpolyfrog.stl")
 	
@@ -506,10 +588,5 @@ func createFrogScene() (Hittable_list, Camera) {
 	ground := NewSphere(NewPoint3(0, -1000, 0), 1000, &groundMat)
 	world.Add(&ground)
 
-	// Sun light
-	sunKey := NewDiffuse_light(NewColor(10, 10, 10))
-	sun := NewSphere(NewPoint3(50, 100, 50), 10, &sunKey)
-	world.Add(&sun)
-
 	return world, cam
 }
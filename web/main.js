@@ -10,6 +10,8 @@ const canvas = document.getElementById('canvas');
 const ctx = canvas.getContext('2d');
 const renderBtn = document.getElementById('renderBtn');
 const cancelBtn = document.getElementById('cancelBtn');
+const gifBtn = document.getElementById('gifBtn');
+const apngBtn = document.getElementById('apngBtn');
 const sceneSelect = document.getElementById('scene');
 const widthInput = document.getElementById('width');
 const samplesInput = document.getElementById('samples');
@@ -198,6 +200,8 @@ async function render() {
     goSetScene(scene);
 
     renderBtn.classList.add('hidden');
+    gifBtn.classList.add('hidden');
+    apngBtn.classList.add('hidden');
     cancelBtn.classList.remove('hidden');
     showProgress();
     updateProgress(0, 1, '0.0', 'Starting...');
@@ -215,10 +219,31 @@ async function render() {
 
     cancelBtn.classList.add('hidden');
     renderBtn.classList.remove('hidden');
+    gifBtn.classList.remove('hidden');
+    apngBtn.classList.remove('hidden');
     renderBtn.disabled = false;
     isRendering = false;
 }
 
+// Download the progressive refinement as a convergence animation
+function exportConvergence(format) {
+    if (!wasmReady || isRendering) return;
+
+    const data = goExportConvergence(format, 15, true);
+    if (!data) return;
+
+    const mime = format === 'apng' ? 'image/apng' : 'image/gif';
+    const ext = format === 'apng' ? 'png' : 'gif';
+    const url = URL.createObjectURL(new Blob([data], { type: mime }));
+
+    const link = document.createElement('a');
+    link.href = url;
+    link.download = `${sceneSelect.value}_convergence.${ext}`;
+    link.click();
+    // Revoking right away can cancel the download in some browsers
+    setTimeout(() => URL.revokeObjectURL(url), 1000);
+}
+
 // Cancel render
 function cancelRender() {
     if (isRendering) {
@@ -237,6 +262,8 @@ function resetCancelBtn() {
 // Event listeners
 renderBtn.addEventListener('click', render);
 cancelBtn.addEventListener('click', cancelRender);
+gifBtn.addEventListener('click', () => exportConvergence('gif'));
+apngBtn.addEventListener('click', () => exportConvergence('apng'));
 
 // Reset cancel button when showing render button
 const observer = new MutationObserver(() => {